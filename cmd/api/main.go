@@ -1,14 +1,19 @@
 package main
 
 import (
+	"dummy-backend/lib/domain"
 	"dummy-backend/lib/handler"
 	"dummy-backend/lib/repository"
 	"dummy-backend/lib/service"
 	"dummy-backend/pkg/config"
 	"dummy-backend/pkg/database"
+	"dummy-backend/pkg/hasher"
+	"dummy-backend/pkg/mailer"
 	"dummy-backend/pkg/middleware"
+	"dummy-backend/pkg/sessioncookie"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -22,6 +27,9 @@ func main() {
 
 	// Load configuration
 	cfg := config.LoadConfig()
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("Invalid configuration:", err)
+	}
 
 	// Set gin mode
 	gin.SetMode(cfg.GinMode)
@@ -29,23 +37,115 @@ func main() {
 	// Initialize database
 	db := database.NewPostgresDB(cfg.DatabaseDSN)
 
+	// Initialize mailer
+	mail, err := mailer.New(cfg)
+	if err != nil {
+		log.Fatal("Failed to initialize mailer:", err)
+	}
+
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db)
 	taskRepo := repository.NewTaskRepository(db)
+	labelRepo := repository.NewLabelRepository(db)
+	projectRepo := repository.NewProjectRepository(db)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	revocationRepo := repository.NewCachedTokenRevocationRepository(
+		repository.NewTokenRevocationRepository(db), cfg.RevocationCacheTTL)
+	passwordResetRepo := repository.NewPasswordResetRepository(db)
+	magicLinkRepo := repository.NewMagicLinkRepository(db)
+	recoveryCodeRepo := repository.NewRecoveryCodeRepository(db)
+	patRepo := repository.NewPersonalAccessTokenRepository(db)
+	transactor := repository.NewTransactor(db)
+	signingKeyRepo := repository.NewSigningKeyRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	oidcStateRepo := repository.NewOIDCLoginStateRepository(db)
+	identityRepo := repository.NewUserIdentityRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	loginAttemptStore := repository.NewPostgresLoginAttemptStore(db)
+	if cfg.LoginAttemptStore == "memory" {
+		loginAttemptStore = repository.NewMemoryLoginAttemptStore()
+	}
 
 	// Initialize services
-	authService := service.NewAuthService(userRepo, cfg.JWTSecret)
-	taskService := service.NewTaskService(taskRepo)
+	keyManager, err := service.NewKeyManager(signingKeyRepo, cfg)
+	if err != nil {
+		log.Fatal("Failed to load signing keys:", err)
+	}
+	passwordPolicy, err := service.NewPasswordPolicy(cfg)
+	if err != nil {
+		log.Fatal("Invalid password policy:", err)
+	}
+	passwordHasher, err := hasher.New(cfg)
+	if err != nil {
+		log.Fatal("Invalid password hashing settings:", err)
+	}
+	cookies, err := sessioncookie.New(cfg)
+	if err != nil {
+		log.Fatal("Invalid session cookie settings:", err)
+	}
+	verificationService := service.NewEmailVerificationService(userRepo, mail, cfg)
+	twoFactorService := service.NewTwoFactorService(userRepo, recoveryCodeRepo, cfg)
+	loginGuard := service.NewLoginGuard(loginAttemptStore, auditRepo, cfg)
+	authService := service.NewAuthService(userRepo, refreshTokenRepo, revocationRepo, sessionRepo, patRepo, transactor, verificationService, twoFactorService, keyManager, loginGuard, passwordPolicy, passwordHasher, cfg)
+	taskService, err := service.NewTaskService(taskRepo, userRepo, labelRepo, projectRepo, cfg)
+	if err != nil {
+		log.Fatal("Invalid task settings:", err)
+	}
+	labelService := service.NewLabelService(labelRepo)
+	projectService := service.NewProjectService(projectRepo)
+	patService := service.NewPersonalAccessTokenService(patRepo, cfg)
+	oidcService := service.NewOIDCService(oidcStateRepo, identityRepo, userRepo, authService, verificationService, cfg)
+	userService := service.NewUserService(userRepo, taskRepo, auditRepo, authService, verificationService, loginGuard, passwordPolicy, passwordHasher, mail, cfg)
+	adminService := service.NewAdminService(userRepo, auditRepo, authService, taskService)
+	passwordResetService := service.NewPasswordResetService(userRepo, passwordResetRepo, authService, passwordPolicy, passwordHasher, mail, cfg)
+	magicLinkService := service.NewMagicLinkService(userRepo, magicLinkRepo, loginAttemptStore, authService, verificationService, mail, cfg)
+	reminderService := service.NewReminderService(taskRepo, mail)
+
+	// Delete accounts whose grace period has ended
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for ; ; <-ticker.C {
+			purgeDeletedAccounts(userService)
+		}
+	}()
+
+	// Email task reminders as they fall due
+	go func() {
+		ticker := time.NewTicker(cfg.ReminderInterval)
+		defer ticker.Stop()
+		for ; ; <-ticker.C {
+			sendTaskReminders(reminderService)
+		}
+	}()
 
 	// Initialize handlers
-	authHandler := handler.NewAuthHandler(authService)
+	authHandler := handler.NewAuthHandler(authService, cookies)
 	taskHandler := handler.NewTaskHandler(taskService)
+	labelHandler := handler.NewLabelHandler(labelService)
+	projectHandler := handler.NewProjectHandler(projectService, taskService)
+	verificationHandler := handler.NewEmailVerificationHandler(verificationService)
+	twoFactorHandler := handler.NewTwoFactorHandler(twoFactorService)
+	patHandler := handler.NewPersonalAccessTokenHandler(patService)
+	jwksHandler := handler.NewJWKSHandler(keyManager)
+	oidcHandler := handler.NewOIDCHandler(oidcService, cookies)
+	userHandler := handler.NewUserHandler(userService)
+	adminHandler := handler.NewAdminHandler(adminService)
+	passwordResetHandler := handler.NewPasswordResetHandler(passwordResetService)
+	magicLinkHandler := handler.NewMagicLinkHandler(magicLinkService, cookies)
+	cronHandler := handler.NewCronHandler(userService, reminderService)
 
 	// Initialize router
 	router := gin.Default()
 
+	// Only believe X-Forwarded-For from known proxies; with none configured
+	// the client IP is the address of the connection
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
+
 	// Apply middleware
-	router.Use(middleware.CORSMiddleware())
+	router.Use(middleware.CORSMiddleware(cfg))
 
 	// Health check endpoint
 	router.GET("/health", func(c *gin.Context) {
@@ -56,6 +156,9 @@ func main() {
 		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
 	})
 
+	// Public keys for verifying access tokens
+	router.GET("/.well-known/jwks.json", jwksHandler.GetJWKS)
+
 	// API routes
 	api := router.Group("/api")
 	{
@@ -64,17 +167,119 @@ func main() {
 		{
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/login", authHandler.Login)
+			auth.POST("/refresh", authHandler.Refresh)
+			auth.POST("/2fa/verify", authHandler.VerifyTwoFactor)
+			auth.POST("/forgot-password", passwordResetHandler.ForgotPassword)
+			auth.POST("/reset-password", passwordResetHandler.ResetPassword)
+			auth.POST("/magic-link", magicLinkHandler.SendLink)
+			auth.GET("/magic-link/callback", magicLinkHandler.Callback)
+			auth.GET("/verify", verificationHandler.Verify)
+			auth.GET("/oidc/providers", oidcHandler.ListProviders)
+			auth.POST("/oidc/:provider/authorize", oidcHandler.Authorize)
+			auth.POST("/oidc/callback", oidcHandler.Callback)
+		}
+
+		// Session routes (authentication required, personal access tokens not accepted)
+		session := api.Group("/auth")
+		session.Use(middleware.AuthMiddleware(authService, nil, cookies))
+		{
+			session.POST("/logout", authHandler.Logout)
+			session.POST("/logout-all", authHandler.LogoutAll)
+			session.GET("/sessions", authHandler.ListSessions)
+			session.DELETE("/sessions/:id", authHandler.RevokeSession)
+			session.POST("/verify/resend", verificationHandler.Resend)
+			session.POST("/2fa/setup", twoFactorHandler.Setup)
+			session.POST("/2fa/confirm", twoFactorHandler.Confirm)
+			session.POST("/2fa/disable", twoFactorHandler.Disable)
+			session.POST("/2fa/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes)
+			session.POST("/tokens", patHandler.CreateToken)
+			session.GET("/tokens", patHandler.ListTokens)
+			session.DELETE("/tokens/:id", patHandler.RevokeToken)
+		}
+
+		// Account routes (JWT required)
+		users := api.Group("/users")
+		users.Use(middleware.AuthMiddleware(authService, nil, cookies))
+		{
+			users.GET("/me", userHandler.GetProfile)
+			users.PATCH("/me", userHandler.UpdateProfile)
+			users.POST("/me/password", userHandler.ChangePassword)
+			users.POST("/me/email", userHandler.ChangeEmail)
+			users.DELETE("/me", userHandler.DeleteAccount)
+			users.POST("/me/restore", userHandler.RestoreAccount)
+			users.GET("/me/export", userHandler.ExportData)
 		}
 
 		// Task routes (authentication required)
 		tasks := api.Group("/tasks")
-		tasks.Use(middleware.AuthMiddleware(authService))
+		tasks.Use(middleware.AuthMiddleware(authService, patService, cookies))
+		if cfg.RequireEmailVerification {
+			tasks.Use(middleware.RequireVerifiedEmail())
+		}
+		{
+			read := middleware.RequireScope(domain.ScopeTasksRead)
+			write := middleware.RequireScope(domain.ScopeTasksWrite)
+			tasks.POST("", write, taskHandler.CreateTask)
+			tasks.GET("", read, taskHandler.GetAllTasks)
+			tasks.GET("/:id", read, taskHandler.GetTaskByID)
+			tasks.PUT("/:id", write, taskHandler.UpdateTask)
+			tasks.DELETE("/:id", write, taskHandler.DeleteTask)
+			tasks.GET("/:id/subtasks", read, taskHandler.ListSubtasks)
+			tasks.GET("/:id/subtree", read, taskHandler.GetSubtree)
+		}
+
+		// Label routes (authentication required, same scopes as tasks)
+		labels := api.Group("/labels")
+		labels.Use(middleware.AuthMiddleware(authService, patService, cookies))
+		if cfg.RequireEmailVerification {
+			labels.Use(middleware.RequireVerifiedEmail())
+		}
+		{
+			read := middleware.RequireScope(domain.ScopeTasksRead)
+			write := middleware.RequireScope(domain.ScopeTasksWrite)
+			labels.POST("", write, labelHandler.CreateLabel)
+			labels.GET("", read, labelHandler.ListLabels)
+			labels.GET("/:id", read, labelHandler.GetLabel)
+			labels.PUT("/:id", write, labelHandler.UpdateLabel)
+			labels.DELETE("/:id", write, labelHandler.DeleteLabel)
+		}
+
+		// Project routes (authentication required, same scopes as tasks)
+		projects := api.Group("/projects")
+		projects.Use(middleware.AuthMiddleware(authService, patService, cookies))
+		if cfg.RequireEmailVerification {
+			projects.Use(middleware.RequireVerifiedEmail())
+		}
+		{
+			read := middleware.RequireScope(domain.ScopeTasksRead)
+			write := middleware.RequireScope(domain.ScopeTasksWrite)
+			projects.POST("", write, projectHandler.CreateProject)
+			projects.GET("", read, projectHandler.ListProjects)
+			projects.GET("/:id", read, projectHandler.GetProject)
+			projects.PUT("/:id", write, projectHandler.UpdateProject)
+			projects.DELETE("/:id", write, projectHandler.DeleteProject)
+			projects.GET("/:id/tasks", read, projectHandler.ListProjectTasks)
+		}
+
+		// Admin routes (admin role required)
+		admin := api.Group("/admin")
+		admin.Use(middleware.AuthMiddleware(authService, nil, cookies), middleware.RequireRole(domain.RoleAdmin))
 		{
-			tasks.POST("", taskHandler.CreateTask)
-			tasks.GET("", taskHandler.GetAllTasks)
-			tasks.GET("/:id", taskHandler.GetTaskByID)
-			tasks.PUT("/:id", taskHandler.UpdateTask)
-			tasks.DELETE("/:id", taskHandler.DeleteTask)
+			admin.GET("/users", adminHandler.ListUsers)
+			admin.POST("/users/:id/disable", adminHandler.DisableUser)
+			admin.POST("/users/:id/enable", adminHandler.EnableUser)
+			admin.PUT("/users/:id/role", adminHandler.SetRole)
+			admin.GET("/users/:id/tasks", adminHandler.ListUserTasks)
+		}
+
+		// Background jobs, triggered by a scheduler (CRON_SECRET required)
+		if cfg.CronSecret != "" {
+			cron := api.Group("/cron")
+			cron.Use(middleware.RequireCronSecret(cfg.CronSecret))
+			{
+				cron.GET("/reminders", cronHandler.SendReminders)
+				cron.GET("/purge-deleted-accounts", cronHandler.PurgeDeletedAccounts)
+			}
 		}
 	}
 
@@ -83,3 +288,23 @@ func main() {
 	log.Printf("Server starting on port %s", port)
 	log.Fatal(router.Run(port))
 }
+
+func purgeDeletedAccounts(userService service.UserService) {
+	deleted, err := userService.PurgeDeletedAccounts()
+	if err != nil {
+		log.Printf("Failed to purge deleted accounts: %v", err)
+	}
+	if deleted > 0 {
+		log.Printf("Purged %d deleted accounts", deleted)
+	}
+}
+
+func sendTaskReminders(reminderService service.ReminderService) {
+	sent, err := reminderService.SendDueReminders()
+	if err != nil {
+		log.Printf("Failed to send task reminders: %v", err)
+	}
+	if sent > 0 {
+		log.Printf("Sent %d task reminders", sent)
+	}
+}
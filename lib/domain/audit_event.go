@@ -0,0 +1,27 @@
+package domain
+
+import "time"
+
+// AuditEvent records a security-relevant action. UserID is nil when the
+// action is not tied to a known account.
+type AuditEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    *uint     `json:"user_id" gorm:"index"`
+	Action    string    `json:"action" gorm:"not null;index"`
+	Subject   string    `json:"subject"`
+	IP        string    `json:"ip"`
+	Details   string    `json:"details"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime;index"`
+}
+
+// Audit actions
+const (
+	AuditLoginLockout = "login.lockout"
+	AuditUserDisabled = "user.disabled"
+	AuditUserEnabled  = "user.enabled"
+	AuditRoleChanged  = "user.role_changed"
+
+	AuditDeletionScheduled = "user.deletion_scheduled"
+	AuditDeletionCancelled = "user.deletion_cancelled"
+	AuditUserDeleted       = "user.deleted"
+)
@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+// Label is a user's tag for organizing tasks. Names are unique per user.
+type Label struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"-" gorm:"not null;uniqueIndex:idx_labels_user_name"`
+	User      *User     `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Name      string    `json:"name" gorm:"not null;uniqueIndex:idx_labels_user_name"`
+	Color     string    `json:"color"` // hex color such as #d73a4a
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// CreateLabelRequest represents the request payload for creating a label
+type CreateLabelRequest struct {
+	Name  string `json:"name" binding:"required,max=50"`
+	Color string `json:"color" binding:"omitempty,hexcolor"`
+}
+
+// UpdateLabelRequest represents the request payload for updating a label
+type UpdateLabelRequest struct {
+	Name  *string `json:"name,omitempty" binding:"omitempty,min=1,max=50"`
+	Color *string `json:"color,omitempty" binding:"omitempty,hexcolor"`
+}
@@ -0,0 +1,12 @@
+package domain
+
+import "time"
+
+// LoginAttempt tracks recent failed logins for one key, either an account
+// ("account:<email>") or a client address ("ip:<addr>")
+type LoginAttempt struct {
+	Key           string     `json:"key" gorm:"primaryKey"`
+	Failures      int        `json:"failures" gorm:"not null;default:0"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+}
@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+// MagicLinkToken represents a single-use passwordless login token. Only the
+// SHA-256 hash of the token is persisted.
+type MagicLinkToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	User      *User      `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// MagicLinkRequest represents the request payload for emailing a login link
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
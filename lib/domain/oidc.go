@@ -0,0 +1,45 @@
+package domain
+
+import "time"
+
+// OIDCLoginState is an authorization request in flight. It is looked up by
+// the hash of the state parameter and deleted when the callback arrives.
+// BindingHash is the hash of a secret kept in a cookie of the browser that
+// started the login, so that nobody can finish it in another browser.
+type OIDCLoginState struct {
+	StateHash    string    `json:"-" gorm:"primaryKey"`
+	BindingHash  string    `json:"-" gorm:"not null;default:''"`
+	Provider     string    `json:"provider" gorm:"not null"`
+	CodeVerifier string    `json:"-" gorm:"not null"`
+	Nonce        string    `json:"-" gorm:"not null"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"not null;index"`
+	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// UserIdentity links a user to an account at an OpenID provider
+type UserIdentity struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	User      *User     `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Provider  string    `json:"provider" gorm:"not null;uniqueIndex:idx_identity_provider_subject"`
+	Subject   string    `json:"-" gorm:"not null;uniqueIndex:idx_identity_provider_subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// OIDCProvidersResponse lists the configured login providers
+type OIDCProvidersResponse struct {
+	Providers []string `json:"providers"`
+}
+
+// OIDCAuthorizeResponse holds the provider URL to redirect the browser to
+type OIDCAuthorizeResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+// OIDCCallbackRequest represents the query parameters the provider
+// redirected back with, forwarded by the frontend
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
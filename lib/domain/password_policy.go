@@ -0,0 +1,19 @@
+package domain
+
+// Password policy rules
+const (
+	PasswordRuleMinLength     = "min_length"
+	PasswordRuleMaxLength     = "max_length"
+	PasswordRuleLowercase     = "lowercase"
+	PasswordRuleUppercase     = "uppercase"
+	PasswordRuleDigit         = "digit"
+	PasswordRuleSymbol        = "symbol"
+	PasswordRuleContainsEmail = "contains_email"
+	PasswordRuleBreached      = "breached"
+)
+
+// PasswordViolation describes one password policy rule a password breaks
+type PasswordViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,40 @@
+package domain
+
+import "time"
+
+// Scopes that can be granted to a personal access token
+const (
+	ScopeTasksRead  = "tasks:read"
+	ScopeTasksWrite = "tasks:write"
+)
+
+// PersonalAccessToken represents a long-lived token for scripts and CI.
+// Only the SHA-256 hash is stored; Prefix is kept so users can recognise
+// their tokens in listings.
+type PersonalAccessToken struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"-" gorm:"not null;index"`
+	User       *User      `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Name       string     `json:"name" gorm:"not null"`
+	Prefix     string     `json:"prefix" gorm:"not null"`
+	TokenHash  string     `json:"-" gorm:"uniqueIndex;not null"`
+	Scopes     []string   `json:"scopes" gorm:"serializer:json;not null"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"not null"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// CreatePersonalAccessTokenRequest represents the request payload for minting a token
+type CreatePersonalAccessTokenRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1,dive,oneof=tasks:read tasks:write"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreatePersonalAccessTokenResponse carries the plaintext token, which is
+// only ever returned once
+type CreatePersonalAccessTokenResponse struct {
+	Token               string              `json:"token"`
+	PersonalAccessToken PersonalAccessToken `json:"personal_access_token"`
+}
@@ -0,0 +1,44 @@
+package domain
+
+import "time"
+
+// Project groups a user's tasks. Archiving a project keeps its tasks but
+// hides them from task listings unless they are asked for.
+type Project struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	UserID      uint      `json:"user_id" gorm:"not null;index"`
+	User        *User     `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Name        string    `json:"name" gorm:"not null"`
+	Description string    `json:"description"`
+	Archived    bool      `json:"archived" gorm:"not null;default:false"`
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
+	// OpenTasks counts tasks that are neither done nor cancelled
+	OpenTasks int64 `json:"open_tasks" gorm:"-"`
+	DoneTasks int64 `json:"done_tasks" gorm:"-"`
+}
+
+// ProjectTaskCounts holds the task counts of one project
+type ProjectTaskCounts struct {
+	ProjectID uint
+	Open      int64
+	Done      int64
+}
+
+// CreateProjectRequest represents the request payload for creating a project
+type CreateProjectRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description"`
+}
+
+// UpdateProjectRequest represents the request payload for updating a
+// project. Setting archived to true archives it and false restores it.
+type UpdateProjectRequest struct {
+	Name        *string `json:"name,omitempty" binding:"omitempty,min=1,max=100"`
+	Description *string `json:"description,omitempty"`
+	Archived    *bool   `json:"archived,omitempty"`
+}
+
+// ProjectListQuery represents the query parameters for listing projects
+type ProjectListQuery struct {
+	IncludeArchived bool `form:"include_archived"`
+}
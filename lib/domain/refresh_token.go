@@ -0,0 +1,23 @@
+package domain
+
+import "time"
+
+// RefreshToken represents a stored refresh token. Only the SHA-256 hash of
+// the token is persisted. Every token issued by rotating another shares its
+// FamilyID, which lets the whole chain be revoked when reuse is detected.
+type RefreshToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	User      *User      `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	FamilyID  string     `json:"family_id" gorm:"not null;index"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// RefreshRequest represents the request payload for rotating a refresh token
+type RefreshRequest struct {
+	// RefreshToken may be omitted when it is sent as a session cookie
+	RefreshToken string `json:"refresh_token"`
+}
@@ -0,0 +1,29 @@
+package domain
+
+import "time"
+
+// RevokedToken records an access token that was invalidated before its
+// expiry, identified by its jti claim. A revoked session is recorded the
+// same way under its ID, which access tokens carry in their sid claim.
+// Rows can be dropped once ExpiresAt has passed because the tokens would be
+// rejected anyway.
+type RevokedToken struct {
+	JTI       string    `json:"jti" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null;index"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// UserTokenRevocation invalidates every access token issued to a user at or
+// before RevokedBefore.
+type UserTokenRevocation struct {
+	UserID        uint      `json:"user_id" gorm:"primaryKey;autoIncrement:false"`
+	User          *User     `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	RevokedBefore time.Time `json:"revoked_before" gorm:"not null"`
+}
+
+// LogoutRequest represents the optional payload for logging out. When a
+// refresh token is supplied it is revoked together with the access token.
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
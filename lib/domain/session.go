@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+// Session is one login on one device. Its ID is the family ID shared by the
+// refresh tokens rotated from that login and is carried in the sid claim of
+// the access tokens issued for it.
+type Session struct {
+	ID         string     `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"-" gorm:"not null;index"`
+	User       *User      `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt  *time.Time `json:"-"`
+	Current    bool       `json:"current" gorm:"-"` // whether the request was made with this session
+}
+
+// ClientInfo describes the device making an authentication request
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
@@ -0,0 +1,13 @@
+package domain
+
+import "time"
+
+// SigningKey represents a generated token signing key. PrivateKey holds the
+// PKCS#8 PEM encrypted with a key derived from JWT_SECRET. Keys are kept
+// after they stop signing so tokens they issued can still be verified.
+type SigningKey struct {
+	KID        string    `json:"kid" gorm:"primaryKey"`
+	Algorithm  string    `json:"alg" gorm:"not null"`
+	PrivateKey string    `json:"-" gorm:"not null"`
+	CreatedAt  time.Time `json:"created_at" gorm:"autoCreateTime;index"`
+}
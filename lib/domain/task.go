@@ -1,25 +1,183 @@
 package domain
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
+
+// Task statuses. A task moves between them along the transitions allowed by
+// the task service.
+const (
+	TaskStatusTodo       = "todo"
+	TaskStatusInProgress = "in_progress"
+	TaskStatusBlocked    = "blocked"
+	TaskStatusDone       = "done"
+	TaskStatusCancelled  = "cancelled"
+)
+
+// TaskStatuses lists every status in workflow order
+var TaskStatuses = []string{TaskStatusTodo, TaskStatusInProgress, TaskStatusBlocked, TaskStatusDone, TaskStatusCancelled}
+
+// DefaultTaskTransitions is the workflow used unless another one is
+// configured. Finished tasks can be reopened.
+var DefaultTaskTransitions = map[string][]string{
+	TaskStatusTodo:       {TaskStatusInProgress, TaskStatusBlocked, TaskStatusDone, TaskStatusCancelled},
+	TaskStatusInProgress: {TaskStatusTodo, TaskStatusBlocked, TaskStatusDone, TaskStatusCancelled},
+	TaskStatusBlocked:    {TaskStatusTodo, TaskStatusInProgress, TaskStatusCancelled},
+	TaskStatusDone:       {TaskStatusTodo, TaskStatusInProgress},
+	TaskStatusCancelled:  {TaskStatusTodo},
+}
+
+// Task priorities
+const (
+	TaskPriorityLow    = "low"
+	TaskPriorityMedium = "medium"
+	TaskPriorityHigh   = "high"
+	TaskPriorityUrgent = "urgent"
+)
 
 // Task represents a task entity
 type Task struct {
-	ID          uint      `json:"id" gorm:"primaryKey"`
-	Title       string    `json:"title" gorm:"not null"`
-	Description string    `json:"description"`
-	Completed   bool      `json:"completed" gorm:"default:false"`
-	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	UserID      uint   `json:"user_id" gorm:"not null;index"`
+	User        *User  `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Title       string `json:"title" gorm:"not null"`
+	Description string `json:"description"`
+	Status      string `json:"status" gorm:"not null;default:todo;index"`
+	Priority    string `json:"priority" gorm:"not null;default:medium"`
+	// Deleting a project moves its tasks out of it
+	ProjectID *uint    `json:"project_id" gorm:"index"`
+	Project   *Project `json:"-" gorm:"constraint:OnDelete:SET NULL"`
+	// Deleting a task deletes its subtasks
+	ParentID *uint `json:"parent_id" gorm:"index"`
+	Parent   *Task `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	// CompletedAt is when the task last moved to done
+	CompletedAt *time.Time `json:"completed_at"`
+	// DueAt and RemindAt are instants; clients send them with a UTC offset
+	DueAt    *time.Time `json:"due_at" gorm:"index"`
+	RemindAt *time.Time `json:"remind_at" gorm:"index:idx_tasks_pending_reminders,where:reminded_at IS NULL"`
+	// RemindedAt is when the reminder for RemindAt was sent
+	RemindedAt *time.Time `json:"-"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	Labels     []Label    `json:"labels" gorm:"many2many:task_labels;constraint:OnDelete:CASCADE"`
+	// Progress rolls up all subtasks below the task; it is only filled in
+	// for API responses
+	Progress *TaskProgress `json:"progress,omitempty" gorm:"-"`
+	// Subtasks is only filled in when fetching a subtree
+	Subtasks []Task `json:"subtasks,omitempty" gorm:"-"`
+}
+
+// TaskProgress counts the finished subtasks of a task. Cancelled subtasks
+// are left out of both counts.
+type TaskProgress struct {
+	Done  int64 `json:"done"`
+	Total int64 `json:"total"`
+}
+
+// SubtaskCounts is the TaskProgress of the task with TaskID
+type SubtaskCounts struct {
+	TaskID uint
+	Done   int64
+	Total  int64
 }
 
-// CreateTaskRequest represents the request payload for creating a task
+// MarshalJSON adds the completed flag that tasks had before statuses, so
+// that older clients keep working
+func (t Task) MarshalJSON() ([]byte, error) {
+	type task Task
+	return json.Marshal(struct {
+		task
+		Completed bool `json:"completed"`
+	}{task(t), t.Status == TaskStatusDone})
+}
+
+// CreateTaskRequest represents the request payload for creating a task.
+// Completed is accepted from older clients; true means status done.
 type CreateTaskRequest struct {
-	Title       string `json:"title" binding:"required"`
-	Description string `json:"description"`
+	Title       string     `json:"title" binding:"required"`
+	Description string     `json:"description"`
+	Status      string     `json:"status" binding:"omitempty,oneof=todo in_progress blocked done cancelled"`
+	Priority    string     `json:"priority" binding:"omitempty,oneof=low medium high urgent"`
+	Completed   *bool      `json:"completed,omitempty"`
+	DueAt       *time.Time `json:"due_at"`
+	RemindAt    *time.Time `json:"remind_at"`
+	LabelIDs    []uint     `json:"label_ids"`
+	ProjectID   *uint      `json:"project_id"`
+	ParentID    *uint      `json:"parent_id"`
 }
 
-// UpdateTaskRequest represents the request payload for updating a task
+// UpdateTaskRequest represents the request payload for updating a task.
+// due_at and remind_at are cleared by sending null. Completed is accepted
+// from older clients: true moves the task to done, false reopens it.
+// LabelIDs replaces all of the task's labels; an empty list removes them.
+// ProjectID moves the task to another project, or out of its project with null.
+// ParentID moves it under another task, or to the top level with null.
 type UpdateTaskRequest struct {
-	Title       *string `json:"title,omitempty"`
-	Description *string `json:"description,omitempty"`
-	Completed   *bool   `json:"completed,omitempty"`
+	Title       *string      `json:"title,omitempty"`
+	Description *string      `json:"description,omitempty"`
+	Status      *string      `json:"status,omitempty" binding:"omitempty,oneof=todo in_progress blocked done cancelled"`
+	Priority    *string      `json:"priority,omitempty" binding:"omitempty,oneof=low medium high urgent"`
+	Completed   *bool        `json:"completed,omitempty"`
+	DueAt       OptionalTime `json:"due_at"`
+	RemindAt    OptionalTime `json:"remind_at"`
+	LabelIDs    *[]uint      `json:"label_ids,omitempty"`
+	ProjectID   OptionalUint `json:"project_id"`
+	ParentID    OptionalUint `json:"parent_id"`
+}
+
+// TaskListQuery represents the query parameters accepted by the task list endpoint
+type TaskListQuery struct {
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Cursor string `form:"cursor"`
+	// Status and Priority are comma-separated lists
+	Status        string     `form:"status"`
+	Priority      string     `form:"priority"`
+	Completed     *bool      `form:"completed"`
+	Search        string     `form:"q"`
+	CreatedAfter  *time.Time `form:"created_after"`
+	CreatedBefore *time.Time `form:"created_before"`
+	// Due is overdue, today or week, with days and weeks (from Monday) in
+	// the user's timezone
+	Due string `form:"due" binding:"omitempty,oneof=overdue today week"`
+	// Label is a comma-separated list of label IDs. LabelMode any matches
+	// tasks with at least one of them, all tasks with every one of them.
+	Label     string `form:"label"`
+	LabelMode string `form:"label_mode" binding:"omitempty,oneof=any all"`
+	ProjectID *uint  `form:"project_id"`
+	ParentID  *uint  `form:"parent_id"`
+	// IncludeArchived lists tasks of archived projects too. They are always
+	// listed when filtering by their project or parent.
+	IncludeArchived bool   `form:"include_archived"`
+	Sort            string `form:"sort"`
+}
+
+// TaskFilter is the validated form of TaskListQuery handed to the repository
+type TaskFilter struct {
+	Statuses        []string
+	ExcludeStatuses []string
+	Priorities      []string
+	TitleContains   string
+	CreatedAfter    *time.Time
+	CreatedBefore   *time.Time
+	DueAfter        *time.Time
+	DueBefore       *time.Time
+	LabelIDs        []uint
+	LabelMatchAll   bool
+	ProjectID       *uint
+	ParentID        *uint
+	// ExcludeArchived leaves out tasks of archived projects
+	ExcludeArchived bool
+	SortColumn      string
+	SortDesc        bool
+	// AfterValue and AfterID identify the last row of the previous page
+	AfterValue interface{}
+	AfterID    uint
+	Limit      int
+}
+
+// TaskListResponse represents a single page of tasks
+type TaskListResponse struct {
+	Data       []Task `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      int64  `json:"total"`
 }
@@ -1,19 +1,47 @@
 package domain
 
-import "time"
+import (
+	"strings"
+	"time"
+)
+
+// NormalizeEmail returns the form email addresses are stored and looked up
+// in, so that addresses differing only in case belong to one account
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// User roles
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
 
 // User represents a user entity
 type User struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	Email     string    `json:"email" gorm:"unique;not null"`
-	Password  string    `json:"-" gorm:"not null"` // "-" excludes from JSON
-	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Email       string     `json:"email" gorm:"unique;not null"`
+	Password    string     `json:"-" gorm:"not null"` // "-" excludes from JSON
+	DisplayName string     `json:"display_name"`
+	Timezone    string     `json:"timezone"` // IANA name, e.g. "Europe/Berlin"
+	Locale      string     `json:"locale"`   // BCP 47 tag, e.g. "en-US"
+	Role        string     `json:"role" gorm:"not null;default:user"`
+	DisabledAt  *time.Time `json:"disabled_at"`
+	// DeletionScheduledAt is when a deletion requested by the user takes
+	// effect; until then the account can be restored
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at"`
+	VerifiedAt          *time.Time `json:"verified_at"` // set once the email address is confirmed
+	VerificationSentAt  *time.Time `json:"-"`
+	TOTPSecret          string     `json:"-"`
+	TOTPEnabledAt       *time.Time `json:"two_factor_enabled_at"`
+	TOTPLastStep        int64      `json:"-"` // last accepted time step, to reject replayed codes
+	CreatedAt           time.Time  `json:"created_at" gorm:"autoCreateTime"`
 }
 
 // RegisterRequest represents the request payload for user registration
 type RegisterRequest struct {
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"`
 }
 
 // LoginRequest represents the request payload for user login
@@ -22,8 +50,62 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
-// AuthResponse represents the response for authentication
+// AuthResponse represents the response for authentication. When the user
+// has two-factor authentication enabled, login only returns a challenge
+// token that must be exchanged at /api/auth/2fa/verify.
 type AuthResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token             string `json:"token,omitempty"`
+	RefreshToken      string `json:"refresh_token,omitempty"`
+	ExpiresIn         int64  `json:"expires_in,omitempty"` // access token lifetime in seconds
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken    string `json:"challenge_token,omitempty"`
+	CSRFToken         string `json:"csrf_token,omitempty"` // set instead of the tokens in cookie session mode
+	User              User   `json:"user"`
+}
+
+// UserListQuery represents the query parameters for listing users
+type UserListQuery struct {
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset int    `form:"offset" binding:"omitempty,min=0"`
+	Search string `form:"q"`
+}
+
+// UserListResponse represents a page of users
+type UserListResponse struct {
+	Data  []User `json:"data"`
+	Total int64  `json:"total"`
+}
+
+// SetRoleRequest represents the request payload for changing a user's role
+type SetRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=user admin"`
+}
+
+// UpdateProfileRequest represents the request payload for updating the
+// current user's profile. Omitted fields are left unchanged; an empty
+// string clears the field.
+type UpdateProfileRequest struct {
+	DisplayName *string `json:"display_name" binding:"omitempty,max=100"`
+	Timezone    *string `json:"timezone" binding:"omitempty,timezone"`
+	Locale      *string `json:"locale" binding:"omitempty,bcp47_language_tag"`
+}
+
+// ChangePasswordRequest represents the request payload for changing the
+// current user's password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// ChangeEmailRequest represents the request payload for changing the
+// current user's email address
+type ChangeEmailRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+// DeleteAccountRequest represents the request payload for deleting the
+// current user's account
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
 }
@@ -3,6 +3,9 @@ package handler
 import (
 	"dummy-backend/lib/domain"
 	"dummy-backend/lib/service"
+	"dummy-backend/pkg/sessioncookie"
+	"errors"
+	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -10,10 +13,11 @@ import (
 
 type AuthHandler struct {
 	authService service.AuthService
+	cookies     *sessioncookie.Cookies
 }
 
-func NewAuthHandler(authService service.AuthService) *AuthHandler {
-	return &AuthHandler{authService: authService}
+func NewAuthHandler(authService service.AuthService, cookies *sessioncookie.Cookies) *AuthHandler {
+	return &AuthHandler{authService: authService, cookies: cookies}
 }
 
 // Register godoc
@@ -23,6 +27,7 @@ func NewAuthHandler(authService service.AuthService) *AuthHandler {
 // @Accept json
 // @Produce json
 // @Param user body domain.RegisterRequest true "User registration data"
+// @Param X-Session-Mode header string false "Set to cookie to receive the tokens as HttpOnly cookies"
 // @Success 201 {object} domain.AuthResponse
 // @Failure 400 {object} map[string]string
 // @Router /api/auth/register [post]
@@ -33,13 +38,18 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	response, err := h.authService.Register(&req)
+	response, err := h.authService.Register(&req, clientInfo(c))
 	if err != nil {
+		var policyErr *service.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			respondPasswordPolicy(c, policyErr)
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, response)
+	respondAuth(c, h.cookies, h.cookies.Requested(c), http.StatusCreated, response)
 }
 
 // Login godoc
@@ -49,8 +59,12 @@ func (h *AuthHandler) Register(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param user body domain.LoginRequest true "User login data"
+// @Param X-Session-Mode header string false "Set to cookie to receive the tokens as HttpOnly cookies"
 // @Success 200 {object} domain.AuthResponse
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Router /api/auth/login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req domain.LoginRequest
@@ -59,11 +73,202 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	response, err := h.authService.Login(&req)
+	response, err := h.authService.Login(&req, clientInfo(c))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		var throttled *service.ThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			respondThrottled(c, throttled)
+		case errors.Is(err, service.ErrInvalidCredentials):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrAccountDisabled):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	respondAuth(c, h.cookies, h.cookies.Requested(c), http.StatusOK, response)
+}
+
+// Refresh godoc
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access token and refresh token. The presented refresh token is invalidated. In cookie session mode the body can be omitted; the refresh token cookie is used and replaced, and the X-CSRF-Token header is required.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token body domain.RefreshRequest false "Refresh token"
+// @Success 200 {object} domain.AuthResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /api/auth/refresh [post]
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	// The body is optional in cookie mode
+	var req domain.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fromCookie := false
+	if req.RefreshToken == "" {
+		req.RefreshToken = h.cookies.RefreshToken(c)
+		fromCookie = req.RefreshToken != ""
+	}
+	if req.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
+		return
+	}
+	if fromCookie && !h.cookies.ValidCSRF(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": errCSRF})
+		return
+	}
+
+	response, err := h.authService.Refresh(&req, clientInfo(c))
+	if err != nil {
+		if fromCookie {
+			h.cookies.Clear(c)
+		}
+		if errors.Is(err, service.ErrInvalidRefreshToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrAccountDisabled) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	respondAuth(c, h.cookies, fromCookie || h.cookies.Requested(c), http.StatusOK, response)
+}
+
+// Logout godoc
+// @Summary Logout
+// @Description Revoke the access token used for this request and end its session. Session cookies are cleared.
+// @Tags auth
+// @Accept json
+// @Security BearerAuth
+// @Param token body domain.LogoutRequest false "Refresh token to revoke"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /api/auth/logout [post]
+func (h *AuthHandler) Logout(c *gin.Context) {
+	// The body is optional
+	var req domain.LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.authService.Logout(c.GetUint("user_id"), c.GetString("jti"), c.GetString("sid"), &req); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.cookies.Clear(c)
+
+	c.Status(http.StatusNoContent)
+}
+
+// LogoutAll godoc
+// @Summary Logout everywhere
+// @Description Revoke every access and refresh token of the current user
+// @Tags auth
+// @Security BearerAuth
+// @Success 204
+// @Failure 401 {object} map[string]string
+// @Router /api/auth/logout-all [post]
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	if err := h.authService.LogoutAll(c.GetUint("user_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.cookies.Clear(c)
+
+	c.Status(http.StatusNoContent)
+}
+
+// VerifyTwoFactor godoc
+// @Summary Complete two-factor login
+// @Description Exchange the challenge token returned by login and a TOTP or recovery code for an access token
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body domain.TwoFactorLoginRequest true "Challenge token and code"
+// @Param X-Session-Mode header string false "Set to cookie to receive the tokens as HttpOnly cookies"
+// @Success 200 {object} domain.AuthResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Router /api/auth/2fa/verify [post]
+func (h *AuthHandler) VerifyTwoFactor(c *gin.Context) {
+	var req domain.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.authService.VerifyTwoFactor(&req, clientInfo(c))
+	if err != nil {
+		var throttled *service.ThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			respondThrottled(c, throttled)
+		case errors.Is(err, service.ErrInvalidChallenge), errors.Is(err, service.ErrInvalidTwoFactorCode):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrAccountDisabled):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	respondAuth(c, h.cookies, h.cookies.Requested(c), http.StatusOK, response)
+}
+
+// ListSessions godoc
+// @Summary List sessions
+// @Description List the current user's active sessions with the device and IP address that last used them. The session of this request is marked current.
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} domain.Session
+// @Failure 401 {object} map[string]string
+// @Router /api/auth/sessions [get]
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	sessions, err := h.authService.ListSessions(c.GetUint("user_id"), c.GetString("sid"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession godoc
+// @Summary Revoke a session
+// @Description Sign out one of the current user's sessions. Its refresh token stops working and its access tokens are rejected.
+// @Tags auth
+// @Security BearerAuth
+// @Param id path string true "Session ID"
+// @Success 204
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/auth/sessions/{id} [delete]
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	if err := h.authService.RevokeSession(c.GetUint("user_id"), c.Param("id")); err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
 }
@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"dummy-backend/lib/domain"
+	"dummy-backend/pkg/sessioncookie"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const errCSRF = "CSRF token missing or invalid"
+
+// respondAuth writes a login or refresh response. With asCookies the tokens
+// are set as session cookies and left out of the body, which carries the
+// CSRF token instead. Two-factor challenges are passed through unchanged.
+func respondAuth(c *gin.Context, cookies *sessioncookie.Cookies, asCookies bool, status int, response *domain.AuthResponse) {
+	if asCookies && response.Token != "" {
+		csrfToken, err := cookies.Write(c, response.Token, response.RefreshToken)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		response.Token = ""
+		response.RefreshToken = ""
+		response.CSRFToken = csrfToken
+	}
+
+	c.JSON(status, response)
+}
@@ -0,0 +1,13 @@
+package handler
+
+import (
+	"dummy-backend/lib/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clientInfo describes the device that made the request, as recorded on the
+// session a login starts
+func clientInfo(c *gin.Context) domain.ClientInfo {
+	return domain.ClientInfo{IP: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"dummy-backend/lib/service"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CronHandler runs background jobs on request, for schedulers such as
+// Vercel cron jobs that call an endpoint instead of keeping a process alive
+type CronHandler struct {
+	userService     service.UserService
+	reminderService service.ReminderService
+}
+
+func NewCronHandler(userService service.UserService, reminderService service.ReminderService) *CronHandler {
+	return &CronHandler{userService: userService, reminderService: reminderService}
+}
+
+// SendReminders godoc
+// @Summary Send due task reminders
+// @Description Emails the reminders that have fallen due. Requires CRON_SECRET as bearer token.
+// @Tags cron
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]int
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/cron/reminders [get]
+func (h *CronHandler) SendReminders(c *gin.Context) {
+	sent, err := h.reminderService.SendDueReminders()
+	if err != nil {
+		log.Printf("Failed to send task reminders: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if sent > 0 {
+		log.Printf("Sent %d task reminders", sent)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sent": sent})
+}
+
+// PurgeDeletedAccounts godoc
+// @Summary Purge deleted accounts
+// @Description Deletes accounts whose grace period has ended. Requires CRON_SECRET as bearer token.
+// @Tags cron
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]int
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/cron/purge-deleted-accounts [get]
+func (h *CronHandler) PurgeDeletedAccounts(c *gin.Context) {
+	deleted, err := h.userService.PurgeDeletedAccounts()
+	if err != nil {
+		log.Printf("Failed to purge deleted accounts: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if deleted > 0 {
+		log.Printf("Purged %d deleted accounts", deleted)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted})
+}
@@ -0,0 +1,21 @@
+package handler
+
+import (
+	"dummy-backend/lib/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondThrottled writes a 429 with a Retry-After header
+func respondThrottled(c *gin.Context, err *service.ThrottledError) {
+	c.Header("Retry-After", strconv.Itoa(err.RetryAfterSeconds()))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+}
+
+// respondPasswordPolicy writes a 400 listing each broken rule, so clients
+// can show them next to the password field
+func respondPasswordPolicy(c *gin.Context, err *service.PasswordPolicyError) {
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "violations": err.Violations})
+}
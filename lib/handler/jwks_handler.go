@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"dummy-backend/lib/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JWKSHandler struct {
+	keyManager service.KeyManager
+}
+
+func NewJWKSHandler(keyManager service.KeyManager) *JWKSHandler {
+	return &JWKSHandler{keyManager: keyManager}
+}
+
+// GetJWKS godoc
+// @Summary JSON Web Key Set
+// @Description Public keys that verify access tokens, selected by the kid header. Empty when tokens are signed with HS256.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} keys.JSONWebKeySet
+// @Failure 500 {object} map[string]string
+// @Router /.well-known/jwks.json [get]
+func (h *JWKSHandler) GetJWKS(c *gin.Context) {
+	set, err := h.keyManager.JWKS()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Short enough that verifiers pick up a rotated key before it signs much
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, set)
+}
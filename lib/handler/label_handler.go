@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"dummy-backend/lib/domain"
+	"dummy-backend/lib/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LabelHandler struct {
+	labelService service.LabelService
+}
+
+func NewLabelHandler(labelService service.LabelService) *LabelHandler {
+	return &LabelHandler{labelService: labelService}
+}
+
+// CreateLabel godoc
+// @Summary Create a label
+// @Description Create a label for organizing tasks. Names are unique per user.
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param label body domain.CreateLabelRequest true "Label data"
+// @Success 201 {object} domain.Label
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/labels [post]
+func (h *LabelHandler) CreateLabel(c *gin.Context) {
+	var req domain.CreateLabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	label, err := h.labelService.CreateLabel(c.GetUint("user_id"), &req)
+	if err != nil {
+		respondLabelError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, label)
+}
+
+// ListLabels godoc
+// @Summary List labels
+// @Description List the current user's labels by name
+// @Tags labels
+// @Produce json
+// @Success 200 {array} domain.Label
+// @Failure 500 {object} map[string]string
+// @Router /api/labels [get]
+func (h *LabelHandler) ListLabels(c *gin.Context) {
+	labels, err := h.labelService.ListLabels(c.GetUint("user_id"))
+	if err != nil {
+		respondLabelError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, labels)
+}
+
+// GetLabel godoc
+// @Summary Get label by ID
+// @Description Get a specific label by its ID
+// @Tags labels
+// @Produce json
+// @Param id path int true "Label ID"
+// @Success 200 {object} domain.Label
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/labels/{id} [get]
+func (h *LabelHandler) GetLabel(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid label ID"})
+		return
+	}
+
+	label, err := h.labelService.GetLabel(c.GetUint("user_id"), uint(id))
+	if err != nil {
+		respondLabelError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, label)
+}
+
+// UpdateLabel godoc
+// @Summary Update label
+// @Description Rename or recolor a label. Omitted fields are unchanged.
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param id path int true "Label ID"
+// @Param label body domain.UpdateLabelRequest true "Label data"
+// @Success 200 {object} domain.Label
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/labels/{id} [put]
+func (h *LabelHandler) UpdateLabel(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid label ID"})
+		return
+	}
+
+	var req domain.UpdateLabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	label, err := h.labelService.UpdateLabel(c.GetUint("user_id"), uint(id), &req)
+	if err != nil {
+		respondLabelError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, label)
+}
+
+// DeleteLabel godoc
+// @Summary Delete label
+// @Description Delete a label and remove it from all tasks
+// @Tags labels
+// @Param id path int true "Label ID"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/labels/{id} [delete]
+func (h *LabelHandler) DeleteLabel(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid label ID"})
+		return
+	}
+
+	if err := h.labelService.DeleteLabel(c.GetUint("user_id"), uint(id)); err != nil {
+		respondLabelError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func respondLabelError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrLabelNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrLabelNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidLabelName):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"dummy-backend/lib/domain"
+	"dummy-backend/lib/service"
+	"dummy-backend/pkg/sessioncookie"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MagicLinkHandler struct {
+	magicLinkService service.MagicLinkService
+	cookies          *sessioncookie.Cookies
+}
+
+func NewMagicLinkHandler(magicLinkService service.MagicLinkService, cookies *sessioncookie.Cookies) *MagicLinkHandler {
+	return &MagicLinkHandler{magicLinkService: magicLinkService, cookies: cookies}
+}
+
+// SendLink godoc
+// @Summary Request a login link
+// @Description Email a single-use login link. The response is the same whether or not the email is registered.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body domain.MagicLinkRequest true "Account email"
+// @Success 202 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Router /api/auth/magic-link [post]
+func (h *MagicLinkHandler) SendLink(c *gin.Context) {
+	var req domain.MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.magicLinkService.SendLink(&req); err != nil {
+		var throttled *service.ThrottledError
+		if errors.As(err, &throttled) {
+			respondThrottled(c, throttled)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the email is registered, a login link has been sent"})
+}
+
+// Callback godoc
+// @Summary Log in with a login link
+// @Description Exchange the token from a login link email for tokens
+// @Tags auth
+// @Produce json
+// @Param token query string true "Login link token"
+// @Param X-Session-Mode header string false "Set to cookie to receive the tokens as HttpOnly cookies"
+// @Success 200 {object} domain.AuthResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /api/auth/magic-link/callback [get]
+func (h *MagicLinkHandler) Callback(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+
+	response, err := h.magicLinkService.Login(token, clientInfo(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidMagicLink):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrAccountDisabled):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	respondAuth(c, h.cookies, h.cookies.Requested(c), http.StatusOK, response)
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"dummy-backend/lib/domain"
+	"dummy-backend/lib/service"
+	"dummy-backend/pkg/sessioncookie"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OIDCHandler struct {
+	oidcService service.OIDCService
+	cookies     *sessioncookie.Cookies
+}
+
+func NewOIDCHandler(oidcService service.OIDCService, cookies *sessioncookie.Cookies) *OIDCHandler {
+	return &OIDCHandler{oidcService: oidcService, cookies: cookies}
+}
+
+// ListProviders godoc
+// @Summary List login providers
+// @Description List the OpenID Connect providers users can sign in with
+// @Tags auth
+// @Produce json
+// @Success 200 {object} domain.OIDCProvidersResponse
+// @Router /api/auth/oidc/providers [get]
+func (h *OIDCHandler) ListProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, domain.OIDCProvidersResponse{Providers: h.oidcService.Providers()})
+}
+
+// Authorize godoc
+// @Summary Start provider login
+// @Description Start an authorization code + PKCE login. Redirect the browser to the returned URL. The login is bound to this browser by an HttpOnly cookie, so send the request with credentials.
+// @Tags auth
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Success 200 {object} domain.OIDCAuthorizeResponse
+// @Failure 404 {object} map[string]string
+// @Failure 502 {object} map[string]string
+// @Router /api/auth/oidc/{provider}/authorize [post]
+func (h *OIDCHandler) Authorize(c *gin.Context) {
+	response, binding, err := h.oidcService.Authorize(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		if errors.Is(err, service.ErrUnknownOIDCProvider) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.cookies.WriteOIDCBinding(c, binding, service.OIDCStateTTL)
+	c.JSON(http.StatusOK, response)
+}
+
+// Callback godoc
+// @Summary Complete provider login
+// @Description Exchange the code and state the provider redirected back with for tokens. Must come from the browser that started the login, with its cookies. Accounts are linked by verified email.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param callback body domain.OIDCCallbackRequest true "Code and state from the redirect"
+// @Param X-Session-Mode header string false "Set to cookie to receive the tokens as HttpOnly cookies"
+// @Success 200 {object} domain.AuthResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /api/auth/oidc/callback [post]
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	var req domain.OIDCCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	binding := h.cookies.OIDCBinding(c)
+	h.cookies.ClearOIDCBinding(c)
+	response, err := h.oidcService.Callback(c.Request.Context(), &req, binding, clientInfo(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidOIDCState), errors.Is(err, service.ErrUnknownOIDCProvider):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrOIDCLoginFailed):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrOIDCEmailNotVerified), errors.Is(err, service.ErrAccountDisabled):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	respondAuth(c, h.cookies, h.cookies.Requested(c), http.StatusOK, response)
+}
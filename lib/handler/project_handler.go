@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"dummy-backend/lib/domain"
+	"dummy-backend/lib/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ProjectHandler struct {
+	projectService service.ProjectService
+	taskService    service.TaskService
+}
+
+func NewProjectHandler(projectService service.ProjectService, taskService service.TaskService) *ProjectHandler {
+	return &ProjectHandler{projectService: projectService, taskService: taskService}
+}
+
+// CreateProject godoc
+// @Summary Create a project
+// @Description Create a project to group tasks in
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param project body domain.CreateProjectRequest true "Project data"
+// @Success 201 {object} domain.Project
+// @Failure 400 {object} map[string]string
+// @Router /api/projects [post]
+func (h *ProjectHandler) CreateProject(c *gin.Context) {
+	var req domain.CreateProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	project, err := h.projectService.CreateProject(c.GetUint("user_id"), &req)
+	if err != nil {
+		respondProjectError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, project)
+}
+
+// ListProjects godoc
+// @Summary List projects
+// @Description List the current user's projects by name, with their open and done task counts
+// @Tags projects
+// @Produce json
+// @Param include_archived query bool false "Include archived projects"
+// @Success 200 {array} domain.Project
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/projects [get]
+func (h *ProjectHandler) ListProjects(c *gin.Context) {
+	var query domain.ProjectListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	projects, err := h.projectService.ListProjects(c.GetUint("user_id"), &query)
+	if err != nil {
+		respondProjectError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, projects)
+}
+
+// GetProject godoc
+// @Summary Get project by ID
+// @Description Get a specific project with its open and done task counts
+// @Tags projects
+// @Produce json
+// @Param id path int true "Project ID"
+// @Success 200 {object} domain.Project
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/projects/{id} [get]
+func (h *ProjectHandler) GetProject(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	project, err := h.projectService.GetProject(c.GetUint("user_id"), uint(id))
+	if err != nil {
+		respondProjectError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, project)
+}
+
+// UpdateProject godoc
+// @Summary Update project
+// @Description Rename, describe, archive or restore a project. Omitted fields are unchanged.
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param project body domain.UpdateProjectRequest true "Project data"
+// @Success 200 {object} domain.Project
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/projects/{id} [put]
+func (h *ProjectHandler) UpdateProject(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	var req domain.UpdateProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	project, err := h.projectService.UpdateProject(c.GetUint("user_id"), uint(id), &req)
+	if err != nil {
+		respondProjectError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, project)
+}
+
+// DeleteProject godoc
+// @Summary Delete project
+// @Description Delete a project. Its tasks are kept and no longer belong to a project.
+// @Tags projects
+// @Param id path int true "Project ID"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/projects/{id} [delete]
+func (h *ProjectHandler) DeleteProject(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	if err := h.projectService.DeleteProject(c.GetUint("user_id"), uint(id)); err != nil {
+		respondProjectError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListProjectTasks godoc
+// @Summary List project tasks
+// @Description Get a page of a project's tasks, archived or not. Accepts the filters and sorting of GET /api/tasks.
+// @Tags projects
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param limit query int false "Page size (1-100, default 20)"
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Param sort query string false "created_at or title, prefix with - for descending (default -created_at)"
+// @Success 200 {object} domain.TaskListResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/projects/{id}/tasks [get]
+func (h *ProjectHandler) ListProjectTasks(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	var query domain.TaskListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	if _, err := h.projectService.GetProject(userID, uint(id)); err != nil {
+		respondProjectError(c, err)
+		return
+	}
+
+	projectID := uint(id)
+	query.ProjectID = &projectID
+	tasks, err := h.taskService.ListTasks(userID, &query)
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tasks)
+}
+
+func respondProjectError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrProjectNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidProjectName):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"dummy-backend/lib/domain"
 	"dummy-backend/lib/service"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -19,7 +20,7 @@ func NewTaskHandler(taskService service.TaskService) *TaskHandler {
 
 // CreateTask godoc
 // @Summary Create a new task
-// @Description Create a new task with title and description
+// @Description Create a new task with title, description, labels and optional due and reminder times
 // @Tags tasks
 // @Accept json
 // @Produce json
@@ -34,9 +35,9 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 		return
 	}
 
-	task, err := h.taskService.CreateTask(&req)
+	task, err := h.taskService.CreateTask(c.GetUint("user_id"), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondTaskError(c, err)
 		return
 	}
 
@@ -45,16 +46,38 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 
 // GetAllTasks godoc
 // @Summary Get all tasks
-// @Description Get a list of all tasks
+// @Description Get a page of the current user's tasks, optionally filtered and sorted
 // @Tags tasks
 // @Produce json
-// @Success 200 {array} domain.Task
+// @Param limit query int false "Page size (1-100, default 20)"
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Param status query string false "Comma-separated statuses: todo, in_progress, blocked, done, cancelled"
+// @Param priority query string false "Comma-separated priorities: low, medium, high, urgent"
+// @Param completed query bool false "Filter by completion state (status done or not)"
+// @Param q query string false "Case-insensitive title substring"
+// @Param created_after query string false "RFC 3339 lower bound on created_at (inclusive)"
+// @Param created_before query string false "RFC 3339 upper bound on created_at (exclusive)"
+// @Param due query string false "overdue, today or week, in the user's timezone"
+// @Param label query string false "Comma-separated label IDs"
+// @Param label_mode query string false "any (default) or all of the labels"
+// @Param project_id query int false "Only tasks in this project"
+// @Param parent_id query int false "Only direct subtasks of this task"
+// @Param include_archived query bool false "Include tasks of archived projects"
+// @Param sort query string false "created_at or title, prefix with - for descending (default -created_at)"
+// @Success 200 {object} domain.TaskListResponse
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /api/tasks [get]
 func (h *TaskHandler) GetAllTasks(c *gin.Context) {
-	tasks, err := h.taskService.GetAllTasks()
+	var query domain.TaskListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tasks, err := h.taskService.ListTasks(c.GetUint("user_id"), &query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondTaskError(c, err)
 		return
 	}
 
@@ -79,9 +102,9 @@ func (h *TaskHandler) GetTaskByID(c *gin.Context) {
 		return
 	}
 
-	task, err := h.taskService.GetTaskByID(uint(id))
+	task, err := h.taskService.GetTaskByID(c.GetUint("user_id"), uint(id))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		respondTaskError(c, err)
 		return
 	}
 
@@ -90,7 +113,7 @@ func (h *TaskHandler) GetTaskByID(c *gin.Context) {
 
 // UpdateTask godoc
 // @Summary Update task
-// @Description Update an existing task
+// @Description Update an existing task. Omitted fields are unchanged; due_at, remind_at, project_id and parent_id are cleared with null. Status changes must follow the workflow; a disallowed change returns 409 with the allowed statuses. Completing a task completes its unfinished subtasks, or returns 409 with the IDs of those the workflow does not allow to move to done.
 // @Tags tasks
 // @Accept json
 // @Produce json
@@ -99,6 +122,7 @@ func (h *TaskHandler) GetTaskByID(c *gin.Context) {
 // @Success 200 {object} domain.Task
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
 // @Router /api/tasks/{id} [put]
 func (h *TaskHandler) UpdateTask(c *gin.Context) {
 	idStr := c.Param("id")
@@ -114,9 +138,9 @@ func (h *TaskHandler) UpdateTask(c *gin.Context) {
 		return
 	}
 
-	task, err := h.taskService.UpdateTask(uint(id), &req)
+	task, err := h.taskService.UpdateTask(c.GetUint("user_id"), uint(id), &req)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		respondTaskError(c, err)
 		return
 	}
 
@@ -125,7 +149,7 @@ func (h *TaskHandler) UpdateTask(c *gin.Context) {
 
 // DeleteTask godoc
 // @Summary Delete task
-// @Description Delete a task by ID
+// @Description Delete a task by ID, together with all of its subtasks
 // @Tags tasks
 // @Param id path int true "Task ID"
 // @Success 204
@@ -140,11 +164,93 @@ func (h *TaskHandler) DeleteTask(c *gin.Context) {
 		return
 	}
 
-	err = h.taskService.DeleteTask(uint(id))
+	err = h.taskService.DeleteTask(c.GetUint("user_id"), uint(id))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		respondTaskError(c, err)
 		return
 	}
 
 	c.Status(http.StatusNoContent)
 }
+
+// ListSubtasks godoc
+// @Summary List subtasks
+// @Description Get a page of a task's direct subtasks. Accepts the filters and sorting of GET /api/tasks.
+// @Tags tasks
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param limit query int false "Page size (1-100, default 20)"
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Param sort query string false "created_at or title, prefix with - for descending (default -created_at)"
+// @Success 200 {object} domain.TaskListResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/tasks/{id}/subtasks [get]
+func (h *TaskHandler) ListSubtasks(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var query domain.TaskListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tasks, err := h.taskService.ListSubtasks(c.GetUint("user_id"), uint(id), &query)
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tasks)
+}
+
+// GetSubtree godoc
+// @Summary Get task subtree
+// @Description Get a task with all of its subtasks nested under subtasks, each with its progress
+// @Tags tasks
+// @Produce json
+// @Param id path int true "Task ID"
+// @Success 200 {object} domain.Task
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/tasks/{id}/subtree [get]
+func (h *TaskHandler) GetSubtree(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	task, err := h.taskService.GetSubtree(c.GetUint("user_id"), uint(id))
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+func respondTaskError(c *gin.Context, err error) {
+	var transitionErr *service.StatusTransitionError
+	var blockingErr *service.BlockingSubtasksError
+	switch {
+	case errors.As(err, &transitionErr):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "allowed": transitionErr.Allowed})
+	case errors.As(err, &blockingErr):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "subtasks": blockingErr.TaskIDs})
+	case errors.Is(err, service.ErrTaskNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidTaskQuery), errors.Is(err, service.ErrConflictingTaskStatus),
+		errors.Is(err, service.ErrUnknownTaskLabel), errors.Is(err, service.ErrUnknownTaskProject),
+		errors.Is(err, service.ErrUnknownParentTask), errors.Is(err, service.ErrTaskCycle), errors.Is(err, service.ErrTaskTooDeep):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrProjectArchived):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
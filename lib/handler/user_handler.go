@@ -0,0 +1,222 @@
+package handler
+
+import (
+	"dummy-backend/lib/domain"
+	"dummy-backend/lib/service"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UserHandler struct {
+	userService service.UserService
+}
+
+func NewUserHandler(userService service.UserService) *UserHandler {
+	return &UserHandler{userService: userService}
+}
+
+// GetProfile godoc
+// @Summary Get current user
+// @Description Get the profile of the authenticated user
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} domain.User
+// @Failure 404 {object} map[string]string
+// @Router /api/users/me [get]
+func (h *UserHandler) GetProfile(c *gin.Context) {
+	user, err := h.userService.GetProfile(c.GetUint("user_id"))
+	if err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateProfile godoc
+// @Summary Update current user
+// @Description Update the display name, timezone or locale of the authenticated user. Omitted fields are left unchanged.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param profile body domain.UpdateProfileRequest true "Profile fields"
+// @Success 200 {object} domain.User
+// @Failure 400 {object} map[string]string
+// @Router /api/users/me [patch]
+func (h *UserHandler) UpdateProfile(c *gin.Context) {
+	var req domain.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.userService.UpdateProfile(c.GetUint("user_id"), &req)
+	if err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ChangePassword godoc
+// @Summary Change password
+// @Description Change the password of the authenticated user. All sessions, including the current one, are logged out.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param password body domain.ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Router /api/users/me/password [post]
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	var req domain.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.userService.ChangePassword(c.GetUint("user_id"), &req, c.ClientIP()); err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed"})
+}
+
+// ChangeEmail godoc
+// @Summary Change email address
+// @Description Change the email address of the authenticated user. The new address has to be verified again.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param email body domain.ChangeEmailRequest true "New email and current password"
+// @Success 200 {object} domain.User
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Router /api/users/me/email [post]
+func (h *UserHandler) ChangeEmail(c *gin.Context) {
+	var req domain.ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.userService.ChangeEmail(c.GetUint("user_id"), &req, c.ClientIP())
+	if err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// DeleteAccount godoc
+// @Summary Delete account
+// @Description Schedule the authenticated user's account and tasks for deletion after a grace period and log out all sessions
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param confirmation body domain.DeleteAccountRequest true "Current password"
+// @Success 202 {object} domain.User
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Router /api/users/me [delete]
+func (h *UserHandler) DeleteAccount(c *gin.Context) {
+	var req domain.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.userService.DeleteAccount(c.GetUint("user_id"), &req, c.ClientIP())
+	if err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, user)
+}
+
+// RestoreAccount godoc
+// @Summary Restore account
+// @Description Cancel a scheduled deletion of the authenticated user's account
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} domain.User
+// @Failure 409 {object} map[string]string
+// @Router /api/users/me/restore [post]
+func (h *UserHandler) RestoreAccount(c *gin.Context) {
+	user, err := h.userService.RestoreAccount(c.GetUint("user_id"), c.ClientIP())
+	if err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ExportData godoc
+// @Summary Export account data
+// @Description Download a ZIP archive with the authenticated user's profile and tasks as JSON
+// @Tags users
+// @Produce application/zip
+// @Security BearerAuth
+// @Success 200 {file} file
+// @Failure 404 {object} map[string]string
+// @Router /api/users/me/export [get]
+func (h *UserHandler) ExportData(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if _, err := h.userService.GetProfile(userID); err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="export-%d.zip"`, userID))
+	c.Status(http.StatusOK)
+
+	// The status is already sent, so a failure can only cut the download short
+	if err := h.userService.ExportData(userID, c.Writer); err != nil {
+		log.Printf("Failed to export data of user %d: %v", userID, err)
+	}
+}
+
+func respondUserError(c *gin.Context, err error) {
+	var throttled *service.ThrottledError
+	var policyErr *service.PasswordPolicyError
+	switch {
+	case errors.As(err, &throttled):
+		respondThrottled(c, throttled)
+	case errors.As(err, &policyErr):
+		respondPasswordPolicy(c, policyErr)
+	case errors.Is(err, service.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrIncorrectPassword):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrEmailTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrDeletionScheduled), errors.Is(err, service.ErrNoDeletionPending):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrEmailUnchanged), errors.Is(err, service.ErrPasswordNotSet):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"dummy-backend/lib/domain"
+
+	"gorm.io/gorm"
+)
+
+type AuditRepository interface {
+	Create(event *domain.AuditEvent) error
+	// Anonymize detaches the user's events from the account and blanks
+	// subjects that identify it, keeping the events themselves
+	Anonymize(userID uint, subject string) error
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+func (r *auditRepository) Create(event *domain.AuditEvent) error {
+	return r.db.Create(event).Error
+}
+
+func (r *auditRepository) Anonymize(userID uint, subject string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&domain.AuditEvent{}).Where("user_id = ?", userID).Update("user_id", nil).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&domain.AuditEvent{}).Where("subject = ?", subject).Update("subject", "").Error
+	})
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"dummy-backend/lib/domain"
+
+	"gorm.io/gorm"
+)
+
+// LabelRepository scopes every query to the owning user, like
+// TaskRepository
+type LabelRepository interface {
+	Create(label *domain.Label) error
+	List(userID uint) ([]domain.Label, error)
+	GetByID(userID, id uint) (*domain.Label, error)
+	GetByName(userID uint, name string) (*domain.Label, error)
+	// GetByIDs returns those of the labels that belong to the user
+	GetByIDs(userID uint, ids []uint) ([]domain.Label, error)
+	Update(label *domain.Label) error
+	Delete(userID, id uint) error
+}
+
+type labelRepository struct {
+	db *gorm.DB
+}
+
+func NewLabelRepository(db *gorm.DB) LabelRepository {
+	return &labelRepository{db: db}
+}
+
+func (r *labelRepository) Create(label *domain.Label) error {
+	return r.db.Create(label).Error
+}
+
+func (r *labelRepository) List(userID uint) ([]domain.Label, error) {
+	var labels []domain.Label
+	err := r.db.Where("user_id = ?", userID).Order("name").Find(&labels).Error
+	return labels, err
+}
+
+func (r *labelRepository) GetByID(userID, id uint) (*domain.Label, error) {
+	var label domain.Label
+	err := r.db.Where("user_id = ?", userID).First(&label, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &label, nil
+}
+
+func (r *labelRepository) GetByName(userID uint, name string) (*domain.Label, error) {
+	var label domain.Label
+	err := r.db.Where("user_id = ? AND name = ?", userID, name).First(&label).Error
+	if err != nil {
+		return nil, err
+	}
+	return &label, nil
+}
+
+func (r *labelRepository) GetByIDs(userID uint, ids []uint) ([]domain.Label, error) {
+	var labels []domain.Label
+	err := r.db.Where("user_id = ? AND id IN ?", userID, ids).Find(&labels).Error
+	return labels, err
+}
+
+func (r *labelRepository) Update(label *domain.Label) error {
+	return r.db.Model(&domain.Label{}).
+		Where("id = ? AND user_id = ?", label.ID, label.UserID).
+		Select("name", "color").
+		Updates(label).Error
+}
+
+// Delete also removes the label from its tasks through the cascading join
+// table
+func (r *labelRepository) Delete(userID, id uint) error {
+	return r.db.Where("user_id = ?", userID).Delete(&domain.Label{}, id).Error
+}
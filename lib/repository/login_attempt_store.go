@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"dummy-backend/lib/domain"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LoginAttemptStore keeps failed login counters, and doubles as the counter
+// for other per-address rate limits such as login link requests. Use the in-memory store
+// for a single long-running instance and the Postgres store when requests
+// are spread over many instances, as on serverless platforms.
+type LoginAttemptStore interface {
+	// Get returns the attempt record for key, or nil if there is none
+	Get(key string) (*domain.LoginAttempt, error)
+	// RecordFailure increments the failure counter, restarting it if the
+	// previous failure was before windowStart, and returns the new record
+	RecordFailure(key string, now, windowStart time.Time) (*domain.LoginAttempt, error)
+	Lock(key string, until time.Time) error
+	Reset(key string) error
+}
+
+type postgresLoginAttemptStore struct {
+	db *gorm.DB
+}
+
+func NewPostgresLoginAttemptStore(db *gorm.DB) LoginAttemptStore {
+	return &postgresLoginAttemptStore{db: db}
+}
+
+func (s *postgresLoginAttemptStore) Get(key string) (*domain.LoginAttempt, error) {
+	var attempt domain.LoginAttempt
+	err := s.db.First(&attempt, "key = ?", key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+func (s *postgresLoginAttemptStore) RecordFailure(key string, now, windowStart time.Time) (*domain.LoginAttempt, error) {
+	// A single upsert keeps concurrent failures from losing increments
+	var attempt domain.LoginAttempt
+	err := s.db.Raw(`
+		INSERT INTO login_attempts (key, failures, last_failure_at)
+		VALUES (?, 1, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.last_failure_at < ? THEN 1 ELSE login_attempts.failures + 1 END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING key, failures, last_failure_at, locked_until`,
+		key, now, windowStart,
+	).Scan(&attempt).Error
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+func (s *postgresLoginAttemptStore) Lock(key string, until time.Time) error {
+	return s.db.Model(&domain.LoginAttempt{}).Where("key = ?", key).Update("locked_until", until).Error
+}
+
+func (s *postgresLoginAttemptStore) Reset(key string) error {
+	return s.db.Where("key = ?", key).Delete(&domain.LoginAttempt{}).Error
+}
+
+type memoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]domain.LoginAttempt
+}
+
+func NewMemoryLoginAttemptStore() LoginAttemptStore {
+	return &memoryLoginAttemptStore{attempts: make(map[string]domain.LoginAttempt)}
+}
+
+func (s *memoryLoginAttemptStore) Get(key string) (*domain.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempt, ok := s.attempts[key]
+	if !ok {
+		return nil, nil
+	}
+	return &attempt, nil
+}
+
+func (s *memoryLoginAttemptStore) RecordFailure(key string, now, windowStart time.Time) (*domain.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop stale entries so the map cannot grow without bound
+	for k, attempt := range s.attempts {
+		stale := attempt.LastFailureAt.Before(windowStart)
+		if stale && (attempt.LockedUntil == nil || attempt.LockedUntil.Before(now)) {
+			delete(s.attempts, k)
+		}
+	}
+
+	attempt := s.attempts[key]
+	attempt.Key = key
+	if attempt.LastFailureAt.Before(windowStart) {
+		attempt.Failures = 0
+	}
+	attempt.Failures++
+	attempt.LastFailureAt = now
+	s.attempts[key] = attempt
+	return &attempt, nil
+}
+
+func (s *memoryLoginAttemptStore) Lock(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if attempt, ok := s.attempts[key]; ok {
+		attempt.LockedUntil = &until
+		s.attempts[key] = attempt
+	}
+	return nil
+}
+
+func (s *memoryLoginAttemptStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.attempts, key)
+	return nil
+}
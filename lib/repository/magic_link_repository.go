@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"dummy-backend/lib/domain"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type MagicLinkRepository interface {
+	Create(token *domain.MagicLinkToken) error
+	GetByHash(hash string) (*domain.MagicLinkToken, error)
+	// MarkUsed consumes an unused token and reports whether it was unused
+	MarkUsed(id uint) (bool, error)
+	// InvalidateForUser consumes every outstanding token of the user
+	InvalidateForUser(userID uint) error
+}
+
+type magicLinkRepository struct {
+	db *gorm.DB
+}
+
+func NewMagicLinkRepository(db *gorm.DB) MagicLinkRepository {
+	return &magicLinkRepository{db: db}
+}
+
+func (r *magicLinkRepository) Create(token *domain.MagicLinkToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *magicLinkRepository) GetByHash(hash string) (*domain.MagicLinkToken, error) {
+	var token domain.MagicLinkToken
+	err := r.db.Where("token_hash = ?", hash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *magicLinkRepository) MarkUsed(id uint) (bool, error) {
+	result := r.db.Model(&domain.MagicLinkToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *magicLinkRepository) InvalidateForUser(userID uint) error {
+	return r.db.Model(&domain.MagicLinkToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
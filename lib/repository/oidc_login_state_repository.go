@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"dummy-backend/lib/domain"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OIDCLoginStateRepository interface {
+	// Create stores the state and drops expired ones
+	Create(state *domain.OIDCLoginState) error
+	// Consume deletes and returns the state with the given hash
+	Consume(stateHash string) (*domain.OIDCLoginState, error)
+}
+
+type oidcLoginStateRepository struct {
+	db *gorm.DB
+}
+
+func NewOIDCLoginStateRepository(db *gorm.DB) OIDCLoginStateRepository {
+	return &oidcLoginStateRepository{db: db}
+}
+
+func (r *oidcLoginStateRepository) Create(state *domain.OIDCLoginState) error {
+	if err := r.db.Where("expires_at < ?", time.Now()).Delete(&domain.OIDCLoginState{}).Error; err != nil {
+		return err
+	}
+	return r.db.Create(state).Error
+}
+
+func (r *oidcLoginStateRepository) Consume(stateHash string) (*domain.OIDCLoginState, error) {
+	var state domain.OIDCLoginState
+	result := r.db.Clauses(clause.Returning{}).Where("state_hash = ?", stateHash).Delete(&state)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &state, nil
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"dummy-backend/lib/domain"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PasswordResetRepository interface {
+	Create(token *domain.PasswordResetToken) error
+	GetByHash(hash string) (*domain.PasswordResetToken, error)
+	// MarkUsed consumes an unused token and reports whether it was unused
+	MarkUsed(id uint) (bool, error)
+	// InvalidateForUser consumes every outstanding token of the user
+	InvalidateForUser(userID uint) error
+	// CountCreatedSince counts the tokens issued to the user since the given time
+	CountCreatedSince(userID uint, since time.Time) (int64, error)
+}
+
+type passwordResetRepository struct {
+	db *gorm.DB
+}
+
+func NewPasswordResetRepository(db *gorm.DB) PasswordResetRepository {
+	return &passwordResetRepository{db: db}
+}
+
+func (r *passwordResetRepository) Create(token *domain.PasswordResetToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *passwordResetRepository) GetByHash(hash string) (*domain.PasswordResetToken, error) {
+	var token domain.PasswordResetToken
+	err := r.db.Where("token_hash = ?", hash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *passwordResetRepository) MarkUsed(id uint) (bool, error) {
+	result := r.db.Model(&domain.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *passwordResetRepository) InvalidateForUser(userID uint) error {
+	return r.db.Model(&domain.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
+
+func (r *passwordResetRepository) CountCreatedSince(userID uint, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&domain.PasswordResetToken{}).
+		Where("user_id = ? AND created_at >= ?", userID, since).
+		Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"dummy-backend/lib/domain"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PersonalAccessTokenRepository interface {
+	// WithTx returns the repository working inside the transaction tx
+	WithTx(tx *gorm.DB) PersonalAccessTokenRepository
+	Create(token *domain.PersonalAccessToken) error
+	GetByHash(hash string) (*domain.PersonalAccessToken, error)
+	ListByUser(userID uint) ([]domain.PersonalAccessToken, error)
+	// Revoke reports whether an active token of the user was revoked
+	Revoke(userID, id uint) (bool, error)
+	RevokeAllForUser(userID uint) error
+	TouchLastUsed(id uint, at time.Time) error
+}
+
+type personalAccessTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewPersonalAccessTokenRepository(db *gorm.DB) PersonalAccessTokenRepository {
+	return &personalAccessTokenRepository{db: db}
+}
+
+func (r *personalAccessTokenRepository) WithTx(tx *gorm.DB) PersonalAccessTokenRepository {
+	return &personalAccessTokenRepository{db: tx}
+}
+
+func (r *personalAccessTokenRepository) Create(token *domain.PersonalAccessToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *personalAccessTokenRepository) GetByHash(hash string) (*domain.PersonalAccessToken, error) {
+	var token domain.PersonalAccessToken
+	err := r.db.Preload("User").Where("token_hash = ?", hash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *personalAccessTokenRepository) ListByUser(userID uint) ([]domain.PersonalAccessToken, error) {
+	var tokens []domain.PersonalAccessToken
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL", userID).Order("created_at DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *personalAccessTokenRepository) Revoke(userID, id uint) (bool, error) {
+	result := r.db.Model(&domain.PersonalAccessToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *personalAccessTokenRepository) RevokeAllForUser(userID uint) error {
+	return r.db.Model(&domain.PersonalAccessToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *personalAccessTokenRepository) TouchLastUsed(id uint, at time.Time) error {
+	return r.db.Model(&domain.PersonalAccessToken{}).Where("id = ?", id).Update("last_used_at", at).Error
+}
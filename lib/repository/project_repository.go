@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"dummy-backend/lib/domain"
+
+	"gorm.io/gorm"
+)
+
+// ProjectRepository scopes every query to the owning user, like
+// TaskRepository
+type ProjectRepository interface {
+	Create(project *domain.Project) error
+	List(userID uint, includeArchived bool) ([]domain.Project, error)
+	GetByID(userID, id uint) (*domain.Project, error)
+	Update(project *domain.Project) error
+	Delete(userID, id uint) error
+	// CountTasks returns the open and done task counts of the projects. Projects
+	// without tasks are left out.
+	CountTasks(userID uint, projectIDs []uint) ([]domain.ProjectTaskCounts, error)
+}
+
+type projectRepository struct {
+	db *gorm.DB
+}
+
+func NewProjectRepository(db *gorm.DB) ProjectRepository {
+	return &projectRepository{db: db}
+}
+
+func (r *projectRepository) Create(project *domain.Project) error {
+	return r.db.Create(project).Error
+}
+
+func (r *projectRepository) List(userID uint, includeArchived bool) ([]domain.Project, error) {
+	query := r.db.Where("user_id = ?", userID)
+	if !includeArchived {
+		query = query.Where("NOT archived")
+	}
+	var projects []domain.Project
+	err := query.Order("name, id").Find(&projects).Error
+	return projects, err
+}
+
+func (r *projectRepository) GetByID(userID, id uint) (*domain.Project, error) {
+	var project domain.Project
+	err := r.db.Where("user_id = ?", userID).First(&project, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+func (r *projectRepository) Update(project *domain.Project) error {
+	return r.db.Model(&domain.Project{}).
+		Where("id = ? AND user_id = ?", project.ID, project.UserID).
+		Select("name", "description", "archived").
+		Updates(project).Error
+}
+
+// Delete leaves the project's tasks in place; the foreign key clears their
+// project_id
+func (r *projectRepository) Delete(userID, id uint) error {
+	return r.db.Where("user_id = ?", userID).Delete(&domain.Project{}, id).Error
+}
+
+func (r *projectRepository) CountTasks(userID uint, projectIDs []uint) ([]domain.ProjectTaskCounts, error) {
+	var counts []domain.ProjectTaskCounts
+	err := r.db.Model(&domain.Task{}).
+		Select(
+			"project_id, COUNT(*) FILTER (WHERE status NOT IN ?) AS open, COUNT(*) FILTER (WHERE status = ?) AS done",
+			[]string{domain.TaskStatusDone, domain.TaskStatusCancelled}, domain.TaskStatusDone,
+		).
+		Where("user_id = ? AND project_id IN ?", userID, projectIDs).
+		Group("project_id").
+		Scan(&counts).Error
+	return counts, err
+}
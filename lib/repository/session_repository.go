@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"dummy-backend/lib/domain"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SessionRepository interface {
+	// WithTx returns the repository working inside the transaction tx
+	WithTx(tx *gorm.DB) SessionRepository
+	// Save creates the session or, if it exists, updates the device details,
+	// last-seen and expiry times
+	Save(session *domain.Session) error
+	ListActive(userID uint, now time.Time) ([]domain.Session, error)
+	// Revoke reports whether an active session of the user was revoked
+	Revoke(userID uint, id string) (bool, error)
+	RevokeAllForUser(userID uint) error
+}
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+func (r *sessionRepository) WithTx(tx *gorm.DB) SessionRepository {
+	return &sessionRepository{db: tx}
+}
+
+func (r *sessionRepository) Save(session *domain.Session) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_agent", "ip", "last_seen_at", "expires_at"}),
+	}).Create(session).Error
+}
+
+func (r *sessionRepository) ListActive(userID uint, now time.Time) ([]domain.Session, error) {
+	var sessions []domain.Session
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+func (r *sessionRepository) Revoke(userID uint, id string) (bool, error) {
+	result := r.db.Model(&domain.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *sessionRepository) RevokeAllForUser(userID uint) error {
+	return r.db.Model(&domain.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"dummy-backend/lib/domain"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SigningKeyRepository interface {
+	Create(key *domain.SigningKey) error
+	// ListCreatedAfter returns keys created after t, newest first
+	ListCreatedAfter(t time.Time) ([]domain.SigningKey, error)
+	DeleteCreatedBefore(t time.Time) error
+}
+
+type signingKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewSigningKeyRepository(db *gorm.DB) SigningKeyRepository {
+	return &signingKeyRepository{db: db}
+}
+
+func (r *signingKeyRepository) Create(key *domain.SigningKey) error {
+	return r.db.Create(key).Error
+}
+
+func (r *signingKeyRepository) ListCreatedAfter(t time.Time) ([]domain.SigningKey, error) {
+	var keys []domain.SigningKey
+	err := r.db.Where("created_at > ?", t).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+func (r *signingKeyRepository) DeleteCreatedBefore(t time.Time) error {
+	return r.db.Where("created_at < ?", t).Delete(&domain.SigningKey{}).Error
+}
@@ -2,16 +2,57 @@ package repository
 
 import (
 	"dummy-backend/lib/domain"
+	"fmt"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+// TaskRepository scopes every query to the owning user so that one user
+// can never read or mutate another user's tasks.
 type TaskRepository interface {
+	// Transaction runs fn with a repository whose queries all belong to one
+	// transaction, committed if fn returns nil
+	Transaction(fn func(repo TaskRepository) error) error
 	Create(task *domain.Task) error
-	GetAll() ([]domain.Task, error)
-	GetByID(id uint) (*domain.Task, error)
-	Update(id uint, task *domain.Task) error
-	Delete(id uint) error
+	List(userID uint, filter *domain.TaskFilter) ([]domain.Task, int64, error)
+	GetByID(userID, id uint) (*domain.Task, error)
+	Update(userID, id uint, task *domain.Task) error
+	Delete(userID, id uint) error
+	// SetLabels replaces the task's labels
+	SetLabels(task *domain.Task, labels []domain.Label) error
+	// AncestorIDs returns the IDs from the task up to its top-level task,
+	// starting with the task itself
+	AncestorIDs(userID, id uint) ([]uint, error)
+	// Subtree returns the task and all tasks below it, parents before their
+	// children
+	Subtree(userID, id uint) ([]domain.Task, error)
+	// SubtaskProgress returns the subtask counts of those of the tasks that
+	// have subtasks
+	SubtaskProgress(userID uint, ids []uint) ([]domain.SubtaskCounts, error)
+	// CompleteSubtasks moves the tasks below the task whose status is one of
+	// from to done
+	CompleteSubtasks(userID, id uint, from []string, at time.Time) error
+	// ForEachBatch calls fn with all of the user's tasks in id order, at most
+	// batchSize at a time
+	ForEachBatch(userID uint, batchSize int, fn func([]domain.Task) error) error
+	// ListDueReminders returns unfinished tasks, with their users, whose reminder
+	// time has passed and that have not been reminded about yet
+	ListDueReminders(now time.Time, limit int) ([]domain.Task, error)
+	// ClaimReminder marks the reminder for remindAt as sent. It reports false
+	// if another instance claimed it first or remind_at has changed since.
+	ClaimReminder(id uint, remindAt time.Time) (bool, error)
+	// ReleaseReminder undoes ClaimReminder so that the reminder is retried
+	ReleaseReminder(id uint) error
+}
+
+// likeEscaper escapes LIKE wildcards so user input only matches literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// orderLabels lists a task's labels by name
+func orderLabels(db *gorm.DB) *gorm.DB {
+	return db.Order("name")
 }
 
 type taskRepository struct {
@@ -22,29 +63,206 @@ func NewTaskRepository(db *gorm.DB) TaskRepository {
 	return &taskRepository{db: db}
 }
 
+func (r *taskRepository) Transaction(fn func(repo TaskRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&taskRepository{db: tx})
+	})
+}
+
 func (r *taskRepository) Create(task *domain.Task) error {
 	return r.db.Create(task).Error
 }
 
-func (r *taskRepository) GetAll() ([]domain.Task, error) {
+// List returns one page of the user's tasks together with the number of
+// tasks matching the filter across all pages.
+func (r *taskRepository) List(userID uint, filter *domain.TaskFilter) ([]domain.Task, int64, error) {
+	scope := func(db *gorm.DB) *gorm.DB {
+		db = db.Where("user_id = ?", userID)
+		if len(filter.Statuses) > 0 {
+			db = db.Where("status IN ?", filter.Statuses)
+		}
+		if len(filter.ExcludeStatuses) > 0 {
+			db = db.Where("status NOT IN ?", filter.ExcludeStatuses)
+		}
+		if len(filter.Priorities) > 0 {
+			db = db.Where("priority IN ?", filter.Priorities)
+		}
+		if filter.TitleContains != "" {
+			db = db.Where("title ILIKE ?", "%"+likeEscaper.Replace(filter.TitleContains)+"%")
+		}
+		if filter.CreatedAfter != nil {
+			db = db.Where("created_at >= ?", *filter.CreatedAfter)
+		}
+		if filter.CreatedBefore != nil {
+			db = db.Where("created_at < ?", *filter.CreatedBefore)
+		}
+		if filter.DueAfter != nil {
+			db = db.Where("due_at >= ?", *filter.DueAfter)
+		}
+		if filter.DueBefore != nil {
+			db = db.Where("due_at < ?", *filter.DueBefore)
+		}
+		if filter.ProjectID != nil {
+			db = db.Where("project_id = ?", *filter.ProjectID)
+		}
+		if filter.ParentID != nil {
+			db = db.Where("parent_id = ?", *filter.ParentID)
+		}
+		if filter.ExcludeArchived {
+			archived := r.db.Model(&domain.Project{}).Select("id").Where("archived")
+			db = db.Where("(project_id IS NULL OR project_id NOT IN (?))", archived)
+		}
+		if len(filter.LabelIDs) > 0 {
+			labeled := r.db.Table("task_labels").Select("task_id").Where("label_id IN ?", filter.LabelIDs)
+			if filter.LabelMatchAll {
+				labeled = labeled.Group("task_id").Having("COUNT(DISTINCT label_id) = ?", len(filter.LabelIDs))
+			}
+			db = db.Where("id IN (?)", labeled)
+		}
+		return db
+	}
+
+	var total int64
+	if err := r.db.Model(&domain.Task{}).Scopes(scope).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	direction, op := "ASC", ">"
+	if filter.SortDesc {
+		direction, op = "DESC", "<"
+	}
+
+	// SortColumn comes from the service's whitelist, never from the client
+	query := r.db.Scopes(scope)
+	if filter.AfterValue != nil {
+		query = query.Where(
+			fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", filter.SortColumn, op),
+			filter.AfterValue, filter.AfterValue, filter.AfterID,
+		)
+	}
+
 	var tasks []domain.Task
-	err := r.db.Find(&tasks).Error
-	return tasks, err
+	err := query.
+		Preload("Labels", orderLabels).
+		Order(fmt.Sprintf("%s %s, id %s", filter.SortColumn, direction, direction)).
+		Limit(filter.Limit).
+		Find(&tasks).Error
+	return tasks, total, err
 }
 
-func (r *taskRepository) GetByID(id uint) (*domain.Task, error) {
+func (r *taskRepository) GetByID(userID, id uint) (*domain.Task, error) {
 	var task domain.Task
-	err := r.db.First(&task, id).Error
+	err := r.db.Preload("Labels", orderLabels).Where("user_id = ?", userID).First(&task, id).Error
 	if err != nil {
 		return nil, err
 	}
 	return &task, nil
 }
 
-func (r *taskRepository) Update(id uint, task *domain.Task) error {
-	return r.db.Model(&domain.Task{}).Where("id = ?", id).Updates(task).Error
+// Update writes every editable column, so fields can be cleared or set to
+// false
+func (r *taskRepository) Update(userID, id uint, task *domain.Task) error {
+	return r.db.Model(&domain.Task{}).
+		Where("id = ? AND user_id = ?", id, userID).
+		Select("title", "description", "status", "priority", "project_id", "parent_id", "completed_at", "due_at", "remind_at", "reminded_at").
+		Updates(task).Error
+}
+
+func (r *taskRepository) Delete(userID, id uint) error {
+	return r.db.Where("user_id = ?", userID).Delete(&domain.Task{}, id).Error
+}
+
+func (r *taskRepository) SetLabels(task *domain.Task, labels []domain.Label) error {
+	return r.db.Model(task).Association("Labels").Replace(labels)
+}
+
+// subtreeCTE selects the IDs of the tasks below the task given as its two
+// parameters, user ID and task ID, together with their depth below it
+const subtreeCTE = `WITH RECURSIVE subtree AS (
+	SELECT id, 0 AS depth FROM tasks WHERE user_id = ? AND id = ?
+	UNION ALL
+	SELECT tasks.id, subtree.depth + 1 FROM tasks JOIN subtree ON tasks.parent_id = subtree.id
+)`
+
+func (r *taskRepository) AncestorIDs(userID, id uint) ([]uint, error) {
+	var rows []struct {
+		ID    uint
+		Depth int
+	}
+	err := r.db.Raw(`WITH RECURSIVE ancestors AS (
+	SELECT id, parent_id, 0 AS depth FROM tasks WHERE user_id = ? AND id = ?
+	UNION ALL
+	SELECT tasks.id, tasks.parent_id, ancestors.depth + 1 FROM tasks JOIN ancestors ON tasks.id = ancestors.parent_id
+) SELECT id, depth FROM ancestors ORDER BY depth`, userID, id).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	return ids, nil
+}
+
+func (r *taskRepository) Subtree(userID, id uint) ([]domain.Task, error) {
+	var tasks []domain.Task
+	err := r.db.Preload("Labels", orderLabels).
+		Joins("JOIN ("+subtreeCTE+" SELECT id, depth FROM subtree) AS subtree ON subtree.id = tasks.id", userID, id).
+		Where("tasks.user_id = ?", userID).
+		Order("subtree.depth, tasks.created_at, tasks.id").
+		Find(&tasks).Error
+	return tasks, err
+}
+
+func (r *taskRepository) SubtaskProgress(userID uint, ids []uint) ([]domain.SubtaskCounts, error) {
+	var counts []domain.SubtaskCounts
+	err := r.db.Raw(`WITH RECURSIVE below AS (
+	SELECT parent_id AS task_id, id, status FROM tasks WHERE user_id = ? AND parent_id IN ?
+	UNION ALL
+	SELECT below.task_id, tasks.id, tasks.status FROM tasks JOIN below ON tasks.parent_id = below.id
+) SELECT task_id,
+	COUNT(*) FILTER (WHERE status = ?) AS done,
+	COUNT(*) FILTER (WHERE status <> ?) AS total
+FROM below GROUP BY task_id`,
+		userID, ids, domain.TaskStatusDone, domain.TaskStatusCancelled,
+	).Scan(&counts).Error
+	return counts, err
+}
+
+func (r *taskRepository) CompleteSubtasks(userID, id uint, from []string, at time.Time) error {
+	if len(from) == 0 {
+		return nil
+	}
+	return r.db.Exec(subtreeCTE+` UPDATE tasks SET status = ?, completed_at = ?
+WHERE id IN (SELECT id FROM subtree WHERE depth > 0) AND status IN ?`,
+		userID, id, domain.TaskStatusDone, at, from,
+	).Error
+}
+
+func (r *taskRepository) ForEachBatch(userID uint, batchSize int, fn func([]domain.Task) error) error {
+	var tasks []domain.Task
+	return r.db.Preload("Labels", orderLabels).Where("user_id = ?", userID).FindInBatches(&tasks, batchSize, func(tx *gorm.DB, batch int) error {
+		return fn(tasks)
+	}).Error
+}
+
+func (r *taskRepository) ListDueReminders(now time.Time, limit int) ([]domain.Task, error) {
+	var tasks []domain.Task
+	err := r.db.Preload("User").
+		Where("remind_at <= ? AND reminded_at IS NULL AND status NOT IN ?", now, []string{domain.TaskStatusDone, domain.TaskStatusCancelled}).
+		Order("remind_at").
+		Limit(limit).
+		Find(&tasks).Error
+	return tasks, err
+}
+
+func (r *taskRepository) ClaimReminder(id uint, remindAt time.Time) (bool, error) {
+	result := r.db.Model(&domain.Task{}).
+		Where("id = ? AND remind_at = ? AND reminded_at IS NULL", id, remindAt).
+		Update("reminded_at", time.Now())
+	return result.RowsAffected == 1, result.Error
 }
 
-func (r *taskRepository) Delete(id uint) error {
-	return r.db.Delete(&domain.Task{}, id).Error
+func (r *taskRepository) ReleaseReminder(id uint) error {
+	return r.db.Model(&domain.Task{}).Where("id = ?", id).Update("reminded_at", nil).Error
 }
@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"dummy-backend/lib/domain"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TokenRevocationRepository interface {
+	// WithTx returns the repository working inside the transaction tx
+	WithTx(tx *gorm.DB) TokenRevocationRepository
+	Revoke(token *domain.RevokedToken) error
+	IsRevoked(jti string) (bool, error)
+	RevokeAllForUser(userID uint, before time.Time) error
+	// RevokedBefore returns the cut-off set by RevokeAllForUser, or the
+	// zero time if the user never revoked all sessions.
+	RevokedBefore(userID uint) (time.Time, error)
+}
+
+type tokenRevocationRepository struct {
+	db *gorm.DB
+}
+
+func NewTokenRevocationRepository(db *gorm.DB) TokenRevocationRepository {
+	return &tokenRevocationRepository{db: db}
+}
+
+func (r *tokenRevocationRepository) WithTx(tx *gorm.DB) TokenRevocationRepository {
+	return &tokenRevocationRepository{db: tx}
+}
+
+func (r *tokenRevocationRepository) Revoke(token *domain.RevokedToken) error {
+	// Expired rows are useless, prune them while we are here
+	if err := r.db.Where("expires_at < ?", time.Now()).Delete(&domain.RevokedToken{}).Error; err != nil {
+		return err
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(token).Error
+}
+
+func (r *tokenRevocationRepository) IsRevoked(jti string) (bool, error) {
+	var count int64
+	err := r.db.Model(&domain.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *tokenRevocationRepository) RevokeAllForUser(userID uint, before time.Time) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"revoked_before"}),
+	}).Create(&domain.UserTokenRevocation{UserID: userID, RevokedBefore: before}).Error
+}
+
+func (r *tokenRevocationRepository) RevokedBefore(userID uint) (time.Time, error) {
+	var revocation domain.UserTokenRevocation
+	err := r.db.First(&revocation, "user_id = ?", userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, nil
+	}
+	return revocation.RevokedBefore, err
+}
+
+// cachedTokenRevocationRepository keeps lookups off the database on every
+// request. Revocations are permanent, so positive answers are cached until
+// the token expires. Negative answers and per-user cut-offs are only cached
+// for ttl, which bounds how long a revocation made by another instance can
+// go unnoticed.
+type cachedTokenRevocationRepository struct {
+	TokenRevocationRepository
+	*revocationCache
+}
+
+// revocationCache is shared by a cached repository and the copies WithTx
+// makes of it
+type revocationCache struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	revoked   map[string]time.Time // jti -> token expiry
+	active    map[string]time.Time // jti -> cache entry expiry
+	before    map[uint]cachedCutoff
+	lastSweep time.Time
+}
+
+// revocationCacheSweepInterval is how often stale cache entries are dropped.
+// Sweeping walks the whole cache, so it is not done on every miss.
+const revocationCacheSweepInterval = time.Minute
+
+type cachedCutoff struct {
+	before    time.Time
+	expiresAt time.Time
+}
+
+func NewCachedTokenRevocationRepository(inner TokenRevocationRepository, ttl time.Duration) TokenRevocationRepository {
+	return &cachedTokenRevocationRepository{
+		TokenRevocationRepository: inner,
+		revocationCache: &revocationCache{
+			ttl:     ttl,
+			revoked: make(map[string]time.Time),
+			active:  make(map[string]time.Time),
+			before:  make(map[uint]cachedCutoff),
+		},
+	}
+}
+
+func (r *cachedTokenRevocationRepository) WithTx(tx *gorm.DB) TokenRevocationRepository {
+	return &cachedTokenRevocationRepository{
+		TokenRevocationRepository: r.TokenRevocationRepository.WithTx(tx),
+		revocationCache:           r.revocationCache,
+	}
+}
+
+func (r *cachedTokenRevocationRepository) Revoke(token *domain.RevokedToken) error {
+	if err := r.TokenRevocationRepository.Revoke(token); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.evictExpired(time.Now())
+	r.revoked[token.JTI] = token.ExpiresAt
+	delete(r.active, token.JTI)
+	return nil
+}
+
+func (r *cachedTokenRevocationRepository) IsRevoked(jti string) (bool, error) {
+	now := time.Now()
+	r.mu.Lock()
+	if _, ok := r.revoked[jti]; ok {
+		r.mu.Unlock()
+		return true, nil
+	}
+	if until, ok := r.active[jti]; ok && now.Before(until) {
+		r.mu.Unlock()
+		return false, nil
+	}
+	r.mu.Unlock()
+
+	revoked, err := r.TokenRevocationRepository.IsRevoked(jti)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.evictExpired(now)
+	if revoked {
+		// The real expiry is unknown here; keep it for one cache period
+		r.revoked[jti] = now.Add(r.ttl)
+	} else {
+		r.active[jti] = now.Add(r.ttl)
+	}
+	return revoked, nil
+}
+
+func (r *cachedTokenRevocationRepository) RevokeAllForUser(userID uint, before time.Time) error {
+	if err := r.TokenRevocationRepository.RevokeAllForUser(userID, before); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.before[userID] = cachedCutoff{before: before, expiresAt: time.Now().Add(r.ttl)}
+	return nil
+}
+
+func (r *cachedTokenRevocationRepository) RevokedBefore(userID uint) (time.Time, error) {
+	now := time.Now()
+	r.mu.Lock()
+	if cutoff, ok := r.before[userID]; ok && now.Before(cutoff.expiresAt) {
+		r.mu.Unlock()
+		return cutoff.before, nil
+	}
+	r.mu.Unlock()
+
+	before, err := r.TokenRevocationRepository.RevokedBefore(userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.before[userID] = cachedCutoff{before: before, expiresAt: now.Add(r.ttl)}
+	return before, nil
+}
+
+// evictExpired drops stale entries, at most once per sweep interval.
+// Callers must hold r.mu.
+func (r *cachedTokenRevocationRepository) evictExpired(now time.Time) {
+	if now.Sub(r.lastSweep) < revocationCacheSweepInterval {
+		return
+	}
+	r.lastSweep = now
+
+	for jti, expiresAt := range r.revoked {
+		if now.After(expiresAt) {
+			delete(r.revoked, jti)
+		}
+	}
+	for jti, until := range r.active {
+		if now.After(until) {
+			delete(r.active, jti)
+		}
+	}
+	for userID, cutoff := range r.before {
+		if now.After(cutoff.expiresAt) {
+			delete(r.before, userID)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"dummy-backend/lib/domain"
+
+	"gorm.io/gorm"
+)
+
+type UserIdentityRepository interface {
+	Create(identity *domain.UserIdentity) error
+	GetByProviderSubject(provider, subject string) (*domain.UserIdentity, error)
+}
+
+type userIdentityRepository struct {
+	db *gorm.DB
+}
+
+func NewUserIdentityRepository(db *gorm.DB) UserIdentityRepository {
+	return &userIdentityRepository{db: db}
+}
+
+func (r *userIdentityRepository) Create(identity *domain.UserIdentity) error {
+	return r.db.Create(identity).Error
+}
+
+func (r *userIdentityRepository) GetByProviderSubject(provider, subject string) (*domain.UserIdentity, error) {
+	var identity domain.UserIdentity
+	err := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"dummy-backend/lib/domain"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -10,6 +11,28 @@ type UserRepository interface {
 	Create(user *domain.User) error
 	GetByEmail(email string) (*domain.User, error)
 	GetByID(id uint) (*domain.User, error)
+	UpdatePassword(id uint, passwordHash string) error
+	// UpdateProfile saves the display name, timezone and locale of user
+	UpdateProfile(user *domain.User) error
+	// UpdateEmail changes the address and marks it as unverified
+	UpdateEmail(id uint, email string) error
+	MarkVerified(id uint, at time.Time) error
+	SetVerificationSentAt(id uint, at time.Time) error
+	SetTOTPSecret(id uint, secret string) error
+	EnableTOTP(id uint, at time.Time) error
+	DisableTOTP(id uint) error
+	// AdvanceTOTPStep records step as the last used one and reports false if
+	// an equal or later step was already used
+	AdvanceTOTPStep(id uint, step int64) (bool, error)
+	List(search string, offset, limit int) ([]domain.User, int64, error)
+	SetRole(id uint, role string) error
+	SetDisabledAt(id uint, at *time.Time) error
+	// ScheduleDeletion sets when the account is deleted; nil cancels
+	ScheduleDeletion(id uint, at *time.Time) error
+	ListDueForDeletion(now time.Time) ([]domain.User, error)
+	// Delete removes the user and their tasks. Other rows owned by the user
+	// are removed by their ON DELETE CASCADE constraints.
+	Delete(id uint) error
 }
 
 type userRepository struct {
@@ -21,12 +44,13 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 }
 
 func (r *userRepository) Create(user *domain.User) error {
+	user.Email = domain.NormalizeEmail(user.Email)
 	return r.db.Create(user).Error
 }
 
 func (r *userRepository) GetByEmail(email string) (*domain.User, error) {
 	var user domain.User
-	err := r.db.Where("email = ?", email).First(&user).Error
+	err := r.db.Where("email = ?", domain.NormalizeEmail(email)).First(&user).Error
 	if err != nil {
 		return nil, err
 	}
@@ -41,3 +65,95 @@ func (r *userRepository) GetByID(id uint) (*domain.User, error) {
 	}
 	return &user, nil
 }
+
+func (r *userRepository) UpdatePassword(id uint, passwordHash string) error {
+	return r.db.Model(&domain.User{}).Where("id = ?", id).Update("password", passwordHash).Error
+}
+
+func (r *userRepository) UpdateProfile(user *domain.User) error {
+	return r.db.Model(user).Select("display_name", "timezone", "locale").Updates(user).Error
+}
+
+func (r *userRepository) UpdateEmail(id uint, email string) error {
+	return r.db.Model(&domain.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"email":                domain.NormalizeEmail(email),
+		"verified_at":          nil,
+		"verification_sent_at": nil,
+	}).Error
+}
+
+func (r *userRepository) MarkVerified(id uint, at time.Time) error {
+	return r.db.Model(&domain.User{}).Where("id = ?", id).Update("verified_at", at).Error
+}
+
+func (r *userRepository) SetVerificationSentAt(id uint, at time.Time) error {
+	return r.db.Model(&domain.User{}).Where("id = ?", id).Update("verification_sent_at", at).Error
+}
+
+func (r *userRepository) SetTOTPSecret(id uint, secret string) error {
+	return r.db.Model(&domain.User{}).Where("id = ?", id).Update("totp_secret", secret).Error
+}
+
+func (r *userRepository) EnableTOTP(id uint, at time.Time) error {
+	return r.db.Model(&domain.User{}).Where("id = ?", id).Update("totp_enabled_at", at).Error
+}
+
+func (r *userRepository) DisableTOTP(id uint) error {
+	return r.db.Model(&domain.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"totp_secret":     "",
+		"totp_enabled_at": nil,
+		"totp_last_step":  0,
+	}).Error
+}
+
+func (r *userRepository) AdvanceTOTPStep(id uint, step int64) (bool, error) {
+	result := r.db.Model(&domain.User{}).
+		Where("id = ? AND totp_last_step < ?", id, step).
+		Update("totp_last_step", step)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *userRepository) List(search string, offset, limit int) ([]domain.User, int64, error) {
+	scope := func(db *gorm.DB) *gorm.DB {
+		if search != "" {
+			db = db.Where("email ILIKE ?", "%"+likeEscaper.Replace(search)+"%")
+		}
+		return db
+	}
+
+	var total int64
+	if err := r.db.Model(&domain.User{}).Scopes(scope).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []domain.User
+	err := r.db.Scopes(scope).Order("id").Offset(offset).Limit(limit).Find(&users).Error
+	return users, total, err
+}
+
+func (r *userRepository) SetRole(id uint, role string) error {
+	return r.db.Model(&domain.User{}).Where("id = ?", id).Update("role", role).Error
+}
+
+func (r *userRepository) SetDisabledAt(id uint, at *time.Time) error {
+	return r.db.Model(&domain.User{}).Where("id = ?", id).Update("disabled_at", at).Error
+}
+
+func (r *userRepository) ScheduleDeletion(id uint, at *time.Time) error {
+	return r.db.Model(&domain.User{}).Where("id = ?", id).Update("deletion_scheduled_at", at).Error
+}
+
+func (r *userRepository) ListDueForDeletion(now time.Time) ([]domain.User, error) {
+	var users []domain.User
+	err := r.db.Where("deletion_scheduled_at <= ?", now).Order("id").Find(&users).Error
+	return users, err
+}
+
+func (r *userRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", id).Delete(&domain.Task{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&domain.User{}, id).Error
+	})
+}
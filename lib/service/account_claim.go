@@ -0,0 +1,37 @@
+package service
+
+import (
+	"dummy-backend/lib/domain"
+	"dummy-backend/lib/repository"
+)
+
+// claimUnverified verifies an account for someone who just proved they own
+// its address through a provider or a login link. If the address was never
+// verified, whoever registered it did not prove they own it, so their
+// password, second factor, tokens and sessions are dropped before the real
+// owner gets in. Disabled accounts are left untouched.
+func claimUnverified(user *domain.User, userRepo repository.UserRepository, authService AuthService, verification EmailVerificationService) error {
+	if user.DisabledAt != nil {
+		return ErrAccountDisabled
+	}
+	if user.VerifiedAt != nil {
+		return nil
+	}
+
+	if err := userRepo.UpdatePassword(user.ID, ""); err != nil {
+		return err
+	}
+	if err := userRepo.DisableTOTP(user.ID); err != nil {
+		return err
+	}
+	if err := authService.LogoutAll(user.ID); err != nil {
+		return err
+	}
+	if err := verification.MarkVerified(user); err != nil {
+		return err
+	}
+	user.Password = ""
+	user.TOTPSecret = ""
+	user.TOTPEnabledAt = nil
+	return nil
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"dummy-backend/lib/domain"
+	"dummy-backend/lib/repository"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const defaultUserPageSize = 50
+
+var (
+	ErrUserNotFound     = errors.New("user not found")
+	ErrCannotModifySelf = errors.New("administrators cannot disable or demote themselves")
+)
+
+// AdminService holds operations available to administrators. Every change
+// to an account is written to the audit log with the acting admin as the
+// event's user.
+type AdminService interface {
+	ListUsers(q *domain.UserListQuery) (*domain.UserListResponse, error)
+	DisableUser(adminID, userID uint, clientIP string) error
+	EnableUser(adminID, userID uint, clientIP string) error
+	SetRole(adminID, userID uint, role, clientIP string) error
+	ListUserTasks(userID uint, q *domain.TaskListQuery) (*domain.TaskListResponse, error)
+}
+
+type adminService struct {
+	userRepo    repository.UserRepository
+	auditRepo   repository.AuditRepository
+	authService AuthService
+	taskService TaskService
+}
+
+func NewAdminService(userRepo repository.UserRepository, auditRepo repository.AuditRepository, authService AuthService, taskService TaskService) AdminService {
+	return &adminService{
+		userRepo:    userRepo,
+		auditRepo:   auditRepo,
+		authService: authService,
+		taskService: taskService,
+	}
+}
+
+func (s *adminService) ListUsers(q *domain.UserListQuery) (*domain.UserListResponse, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultUserPageSize
+	}
+
+	users, total, err := s.userRepo.List(q.Search, q.Offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	if users == nil {
+		users = []domain.User{}
+	}
+	return &domain.UserListResponse{Data: users, Total: total}, nil
+}
+
+// DisableUser blocks the account from logging in and ends its sessions
+func (s *adminService) DisableUser(adminID, userID uint, clientIP string) error {
+	if adminID == userID {
+		return ErrCannotModifySelf
+	}
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	now := time.Now()
+	if err := s.userRepo.SetDisabledAt(user.ID, &now); err != nil {
+		return err
+	}
+	if err := s.authService.LogoutAll(user.ID); err != nil {
+		return err
+	}
+
+	return s.audit(adminID, user.ID, domain.AuditUserDisabled, clientIP, "")
+}
+
+func (s *adminService) EnableUser(adminID, userID uint, clientIP string) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	if err := s.userRepo.SetDisabledAt(user.ID, nil); err != nil {
+		return err
+	}
+
+	return s.audit(adminID, user.ID, domain.AuditUserEnabled, clientIP, "")
+}
+
+// SetRole changes the user's role. The role is carried in access tokens, so
+// existing sessions are ended to make the change take effect immediately.
+func (s *adminService) SetRole(adminID, userID uint, role, clientIP string) error {
+	if adminID == userID && role != domain.RoleAdmin {
+		return ErrCannotModifySelf
+	}
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if user.Role == role {
+		return nil
+	}
+
+	if err := s.userRepo.SetRole(user.ID, role); err != nil {
+		return err
+	}
+	if err := s.authService.LogoutAll(user.ID); err != nil {
+		return err
+	}
+
+	return s.audit(adminID, user.ID, domain.AuditRoleChanged, clientIP, fmt.Sprintf("%s -> %s", user.Role, role))
+}
+
+func (s *adminService) ListUserTasks(userID uint, q *domain.TaskListQuery) (*domain.TaskListResponse, error) {
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return nil, ErrUserNotFound
+	}
+	return s.taskService.ListTasks(userID, q)
+}
+
+func (s *adminService) audit(adminID, userID uint, action, clientIP, details string) error {
+	return s.auditRepo.Create(&domain.AuditEvent{
+		UserID:  &adminID,
+		Action:  action,
+		Subject: fmt.Sprintf("user:%d", userID),
+		IP:      clientIP,
+		Details: details,
+	})
+}
@@ -3,40 +3,105 @@ package service
 import (
 	"dummy-backend/lib/domain"
 	"dummy-backend/lib/repository"
+	"dummy-backend/pkg/config"
+	"dummy-backend/pkg/hasher"
 	"errors"
+	"log"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
+var (
+	ErrInvalidCredentials  = errors.New("invalid credentials")
+	ErrAccountDisabled     = errors.New("account is disabled")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrInvalidToken        = errors.New("invalid token")
+	ErrTokenRevoked        = errors.New("token has been revoked")
+	ErrInvalidChallenge    = errors.New("invalid or expired two-factor challenge")
+	ErrSessionNotFound     = errors.New("session not found")
+)
+
+const (
+	accessTokenType    = "access"
+	challengeTokenType = "2fa_challenge"
+	challengeTTL       = 5 * time.Minute
+	// maxUserAgentLength bounds what a client can make us store per session
+	maxUserAgentLength = 512
+)
+
+// AuthService issues and validates tokens. Methods that start or refresh a
+// session take the client's details, which are recorded on the session and
+// used for brute-force tracking.
 type AuthService interface {
-	Register(req *domain.RegisterRequest) (*domain.AuthResponse, error)
-	Login(req *domain.LoginRequest) (*domain.AuthResponse, error)
+	Register(req *domain.RegisterRequest, client domain.ClientInfo) (*domain.AuthResponse, error)
+	Login(req *domain.LoginRequest, client domain.ClientInfo) (*domain.AuthResponse, error)
+	// VerifyTwoFactor completes a login that returned a challenge token
+	VerifyTwoFactor(req *domain.TwoFactorLoginRequest, client domain.ClientInfo) (*domain.AuthResponse, error)
+	// CompleteLogin finishes a login for a user who proved their identity
+	// without a password, e.g. through an OpenID provider. Two-factor
+	// authentication still applies.
+	CompleteLogin(user *domain.User, client domain.ClientInfo) (*domain.AuthResponse, error)
+	Refresh(req *domain.RefreshRequest, client domain.ClientInfo) (*domain.AuthResponse, error)
+	// Logout revokes the access token jti and ends the session sid, if any
+	Logout(userID uint, jti, sid string, req *domain.LogoutRequest) error
+	LogoutAll(userID uint) error
+	// ListSessions returns the user's active sessions, flagging currentSID
+	ListSessions(userID uint, currentSID string) ([]domain.Session, error)
+	RevokeSession(userID uint, sid string) error
 	ValidateToken(tokenString string) (*jwt.Token, error)
 }
 
 type authService struct {
-	userRepo  repository.UserRepository
-	jwtSecret []byte
+	userRepo         repository.UserRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+	revocationRepo   repository.TokenRevocationRepository
+	sessionRepo      repository.SessionRepository
+	patRepo          repository.PersonalAccessTokenRepository
+	transactor       repository.Transactor
+	verification     EmailVerificationService
+	twoFactor        TwoFactorService
+	keys             KeyManager
+	loginGuard       LoginGuard
+	passwordPolicy   PasswordPolicy
+	hasher           hasher.Hasher
+	accessTokenTTL   time.Duration
+	refreshTokenTTL  time.Duration
 }
 
-func NewAuthService(userRepo repository.UserRepository, jwtSecret string) AuthService {
+func NewAuthService(userRepo repository.UserRepository, refreshTokenRepo repository.RefreshTokenRepository, revocationRepo repository.TokenRevocationRepository, sessionRepo repository.SessionRepository, patRepo repository.PersonalAccessTokenRepository, transactor repository.Transactor, verification EmailVerificationService, twoFactor TwoFactorService, keys KeyManager, loginGuard LoginGuard, passwordPolicy PasswordPolicy, passwordHasher hasher.Hasher, cfg *config.Config) AuthService {
 	return &authService{
-		userRepo:  userRepo,
-		jwtSecret: []byte(jwtSecret),
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		revocationRepo:   revocationRepo,
+		sessionRepo:      sessionRepo,
+		patRepo:          patRepo,
+		transactor:       transactor,
+		verification:     verification,
+		twoFactor:        twoFactor,
+		keys:             keys,
+		loginGuard:       loginGuard,
+		passwordPolicy:   passwordPolicy,
+		hasher:           passwordHasher,
+		accessTokenTTL:   cfg.AccessTokenTTL,
+		refreshTokenTTL:  cfg.RefreshTokenTTL,
 	}
 }
 
-func (s *authService) Register(req *domain.RegisterRequest) (*domain.AuthResponse, error) {
+func (s *authService) Register(req *domain.RegisterRequest, client domain.ClientInfo) (*domain.AuthResponse, error) {
 	// Check if user already exists
 	existingUser, _ := s.userRepo.GetByEmail(req.Email)
 	if existingUser != nil {
 		return nil, errors.New("user already exists")
 	}
 
+	if err := s.passwordPolicy.Validate(req.Password, req.Email); err != nil {
+		return nil, err
+	}
+
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := s.hasher.Hash(req.Password)
 	if err != nil {
 		return nil, err
 	}
@@ -44,7 +109,8 @@ func (s *authService) Register(req *domain.RegisterRequest) (*domain.AuthRespons
 	// Create user
 	user := &domain.User{
 		Email:    req.Email,
-		Password: string(hashedPassword),
+		Password: hashedPassword,
+		Role:     domain.RoleUser,
 	}
 
 	err = s.userRepo.Create(user)
@@ -52,58 +118,393 @@ func (s *authService) Register(req *domain.RegisterRequest) (*domain.AuthRespons
 		return nil, err
 	}
 
-	// Generate JWT token
-	token, err := s.generateToken(user.ID)
-	if err != nil {
-		return nil, err
+	// The user can ask for another email, so don't fail the registration
+	if err := s.verification.SendVerification(user); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
 	}
 
-	return &domain.AuthResponse{
-		Token: token,
-		User:  *user,
-	}, nil
+	return s.issueTokens(user, "", client)
 }
 
-func (s *authService) Login(req *domain.LoginRequest) (*domain.AuthResponse, error) {
+func (s *authService) Login(req *domain.LoginRequest, client domain.ClientInfo) (*domain.AuthResponse, error) {
+	if err := s.loginGuard.Check(req.Email, client.IP); err != nil {
+		return nil, err
+	}
+
 	// Get user by email
 	user, err := s.userRepo.GetByEmail(req.Email)
 	if err != nil {
-		return nil, errors.New("invalid credentials")
+		s.recordLoginFailure(req.Email, client.IP)
+		return nil, ErrInvalidCredentials
 	}
 
 	// Check password
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
+	ok, needsRehash, err := s.hasher.Verify(req.Password, user.Password)
 	if err != nil {
-		return nil, errors.New("invalid credentials")
+		return nil, err
 	}
+	if !ok {
+		s.recordLoginFailure(req.Email, client.IP)
+		return nil, ErrInvalidCredentials
+	}
+
+	// Move the stored hash to the current algorithm and parameters while
+	// the plaintext is at hand
+	if needsRehash {
+		s.rehashPassword(user, req.Password)
+	}
+
+	return s.CompleteLogin(user, client)
+}
 
-	// Generate JWT token
-	token, err := s.generateToken(user.ID)
+func (s *authService) CompleteLogin(user *domain.User, client domain.ClientInfo) (*domain.AuthResponse, error) {
+	if user.DisabledAt != nil {
+		return nil, ErrAccountDisabled
+	}
+
+	if user.TOTPEnabledAt != nil {
+		challenge, err := s.generateChallengeToken(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &domain.AuthResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+			User:              *user,
+		}, nil
+	}
+
+	s.recordLoginSuccess(user.Email)
+	return s.issueTokens(user, "", client)
+}
+
+func (s *authService) VerifyTwoFactor(req *domain.TwoFactorLoginRequest, client domain.ClientInfo) (*domain.AuthResponse, error) {
+	token, err := jwt.Parse(req.ChallengeToken, s.keys.Keyfunc)
 	if err != nil {
+		return nil, ErrInvalidChallenge
+	}
+	claims, _ := token.Claims.(jwt.MapClaims)
+	userID, _ := claims["user_id"].(float64)
+	if claims["typ"] != challengeTokenType || userID <= 0 {
+		return nil, ErrInvalidChallenge
+	}
+
+	user, err := s.userRepo.GetByID(uint(userID))
+	if err != nil {
+		return nil, ErrInvalidChallenge
+	}
+	if user.DisabledAt != nil {
+		return nil, ErrAccountDisabled
+	}
+
+	// Code guesses count against the same limits as password guesses
+	if err := s.loginGuard.Check(user.Email, client.IP); err != nil {
+		return nil, err
+	}
+	if err := s.twoFactor.VerifyCode(user, req.Code); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			s.recordLoginFailure(user.Email, client.IP)
+		}
 		return nil, err
 	}
 
-	return &domain.AuthResponse{
-		Token: token,
-		User:  *user,
-	}, nil
+	s.recordLoginSuccess(user.Email)
+	return s.issueTokens(user, "", client)
+}
+
+// rehashPassword only logs failures: the old hash keeps working and the
+// upgrade is retried at the next login.
+func (s *authService) rehashPassword(user *domain.User, password string) {
+	hashedPassword, err := s.hasher.Hash(password)
+	if err == nil {
+		err = s.userRepo.UpdatePassword(user.ID, hashedPassword)
+	}
+	if err != nil {
+		log.Printf("Failed to upgrade password hash of user %d: %v", user.ID, err)
+		return
+	}
+	user.Password = hashedPassword
+}
+
+// recordLoginFailure and recordLoginSuccess only log storage errors: the
+// outcome of the attempt itself is already decided.
+func (s *authService) recordLoginFailure(email, clientIP string) {
+	if err := s.loginGuard.RecordFailure(email, clientIP); err != nil {
+		log.Printf("Failed to record failed login: %v", err)
+	}
+}
+
+func (s *authService) recordLoginSuccess(email string) {
+	if err := s.loginGuard.RecordSuccess(email); err != nil {
+		log.Printf("Failed to reset login attempts: %v", err)
+	}
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token pair.
+// Each refresh token can be used once; presenting one that was already
+// rotated means it has leaked, so the whole session is ended.
+func (s *authService) Refresh(req *domain.RefreshRequest, client domain.ClientInfo) (*domain.AuthResponse, error) {
+	stored, err := s.refreshTokenRepo.GetByHash(hashToken(req.RefreshToken))
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if stored.RevokedAt != nil {
+		if err := s.endSession(stored.UserID, stored.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	rotated, err := s.refreshTokenRepo.Revoke(stored.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		// Lost a race with another request presenting the same token
+		if err := s.endSession(stored.UserID, stored.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.userRepo.GetByID(stored.UserID)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if user.DisabledAt != nil {
+		return nil, ErrAccountDisabled
+	}
+
+	return s.issueTokens(user, stored.FamilyID, client)
+}
+
+// Logout revokes the access token identified by jti and ends its session.
+// Access tokens issued before sessions were tracked carry no sid; for those
+// the refresh token, if given, identifies the session.
+func (s *authService) Logout(userID uint, jti, sid string, req *domain.LogoutRequest) error {
+	err := s.revocationRepo.Revoke(&domain.RevokedToken{
+		JTI:       jti,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(s.accessTokenTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	if sid == "" && req.RefreshToken != "" {
+		stored, err := s.refreshTokenRepo.GetByHash(hashToken(req.RefreshToken))
+		if err == nil && stored.UserID == userID {
+			sid = stored.FamilyID
+		}
+	}
+	if sid == "" {
+		return nil
+	}
+	return s.endSession(userID, sid)
+}
+
+// LogoutAll takes away every credential of the user at once: all access
+// tokens issued so far stop validating, and all refresh tokens, sessions and
+// personal access tokens are revoked.
+func (s *authService) LogoutAll(userID uint) error {
+	now := time.Now()
+	return s.transactor.Transaction(func(tx *gorm.DB) error {
+		if err := s.revocationRepo.WithTx(tx).RevokeAllForUser(userID, now); err != nil {
+			return err
+		}
+		if err := s.refreshTokenRepo.WithTx(tx).RevokeAllForUser(userID); err != nil {
+			return err
+		}
+		if err := s.sessionRepo.WithTx(tx).RevokeAllForUser(userID); err != nil {
+			return err
+		}
+		return s.patRepo.WithTx(tx).RevokeAllForUser(userID)
+	})
+}
+
+func (s *authService) ListSessions(userID uint, currentSID string) ([]domain.Session, error) {
+	sessions, err := s.sessionRepo.ListActive(userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSID
+	}
+	return sessions, nil
+}
+
+// RevokeSession signs out one of the user's sessions, which may be the
+// current one.
+func (s *authService) RevokeSession(userID uint, sid string) error {
+	revoked, err := s.sessionRepo.Revoke(userID, sid)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrSessionNotFound
+	}
+	return s.endSession(userID, sid)
+}
+
+// endSession revokes the refresh token family of a session and every access
+// token issued for it. Access tokens carry the session ID in their sid
+// claim, so revoking that ID like a jti rejects them all.
+func (s *authService) endSession(userID uint, sid string) error {
+	if _, err := s.sessionRepo.Revoke(userID, sid); err != nil {
+		return err
+	}
+	if err := s.refreshTokenRepo.RevokeFamily(sid); err != nil {
+		return err
+	}
+	return s.revocationRepo.Revoke(&domain.RevokedToken{
+		JTI:       sid,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(s.accessTokenTTL),
+	})
 }
 
 func (s *authService) ValidateToken(tokenString string) (*jwt.Token, error) {
-	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid token")
+	token, err := jwt.Parse(tokenString, s.keys.Keyfunc)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["typ"] != accessTokenType {
+		return nil, ErrInvalidToken
+	}
+	jti, _ := claims["jti"].(string)
+	userID, _ := claims["user_id"].(float64)
+	issuedAt, _ := claims["iat"].(float64)
+	if jti == "" || userID <= 0 || issuedAt <= 0 {
+		return nil, ErrInvalidToken
+	}
+
+	revoked, err := s.revocationRepo.IsRevoked(jti)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	if sid, _ := claims["sid"].(string); sid != "" {
+		revoked, err := s.revocationRepo.IsRevoked(sid)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
 		}
-		return s.jwtSecret, nil
+	}
+
+	before, err := s.revocationRepo.RevokedBefore(uint(userID))
+	if err != nil {
+		return nil, err
+	}
+	if !before.IsZero() && !time.UnixMilli(int64(issuedAt*1000)).After(before) {
+		return nil, ErrTokenRevoked
+	}
+
+	return token, nil
+}
+
+// issueTokens creates an access token and a refresh token for the user. An
+// empty familyID starts a new refresh token family, and with it a new
+// session; otherwise the session's device details and last-seen time are
+// updated.
+func (s *authService) issueTokens(user *domain.User, familyID string, client domain.ClientInfo) (*domain.AuthResponse, error) {
+	var err error
+	if familyID == "" {
+		familyID, err = randomID()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	accessToken, err := s.generateToken(user, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, hash, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.refreshTokenRepo.Create(&domain.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(s.refreshTokenTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	userAgent := client.UserAgent
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	now := time.Now()
+	err = s.sessionRepo.Save(&domain.Session{
+		ID:         familyID,
+		UserID:     user.ID,
+		UserAgent:  userAgent,
+		IP:         client.IP,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.refreshTokenTTL),
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.AuthResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.accessTokenTTL.Seconds()),
+		User:         *user,
+	}, nil
+}
+
+// generateToken signs an access token for the session sid
+func (s *authService) generateToken(user *domain.User, sid string) (string, error) {
+	jti, err := randomID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"typ":            accessTokenType,
+		"user_id":        user.ID,
+		"role":           user.Role,
+		"email_verified": user.VerifiedAt != nil,
+		"jti":            jti,
+		"sid":            sid,
+		// Millisecond precision so that a login right after logout-all
+		// is not caught by the cut-off
+		"iat": float64(now.UnixMilli()) / 1000,
+		"exp": now.Add(s.accessTokenTTL).Unix(),
+	}
+
+	return s.keys.Sign(claims)
 }
 
-func (s *authService) generateToken(userID uint) (string, error) {
+// generateChallengeToken issues the short-lived token returned by the
+// password step of a two-factor login. Its typ claim keeps it from being
+// accepted as an access token.
+func (s *authService) generateChallengeToken(userID uint) (string, error) {
 	claims := jwt.MapClaims{
+		"typ":     challengeTokenType,
 		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(), // 24 hours
+		"exp":     time.Now().Add(challengeTTL).Unix(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(s.jwtSecret)
+	return s.keys.Sign(claims)
 }
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"dummy-backend/lib/domain"
+	"dummy-backend/lib/repository"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// fakeRefreshTokenRepo holds one stored refresh token and records which
+// families were revoked
+type fakeRefreshTokenRepo struct {
+	repository.RefreshTokenRepository
+	stored          *domain.RefreshToken
+	lostRace        bool
+	revokedFamilies []string
+}
+
+func (r *fakeRefreshTokenRepo) GetByHash(hash string) (*domain.RefreshToken, error) {
+	if r.stored == nil || r.stored.TokenHash != hash {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.stored, nil
+}
+
+func (r *fakeRefreshTokenRepo) Revoke(id uint) (bool, error) {
+	return !r.lostRace, nil
+}
+
+func (r *fakeRefreshTokenRepo) RevokeFamily(familyID string) error {
+	r.revokedFamilies = append(r.revokedFamilies, familyID)
+	return nil
+}
+
+// fakeSessionRepo records which sessions were ended
+type fakeSessionRepo struct {
+	repository.SessionRepository
+	revoked []string
+}
+
+func (r *fakeSessionRepo) Revoke(userID uint, id string) (bool, error) {
+	r.revoked = append(r.revoked, id)
+	return true, nil
+}
+
+// fakeRevocationRepo records which token IDs were revoked
+type fakeRevocationRepo struct {
+	repository.TokenRevocationRepository
+	revoked []string
+}
+
+func (r *fakeRevocationRepo) Revoke(token *domain.RevokedToken) error {
+	r.revoked = append(r.revoked, token.JTI)
+	return nil
+}
+
+func TestRefreshDetectsReuse(t *testing.T) {
+	revokedAt := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name       string
+		revokedAt  *time.Time
+		expiresAt  time.Time
+		lostRace   bool
+		wantRevoke bool
+	}{
+		{name: "already rotated token", revokedAt: &revokedAt, expiresAt: time.Now().Add(time.Hour), wantRevoke: true},
+		{name: "rotated by a concurrent request", expiresAt: time.Now().Add(time.Hour), lostRace: true, wantRevoke: true},
+		{name: "expired token", expiresAt: time.Now().Add(-time.Minute)},
+	}
+	for _, tt := range tests {
+		refreshRepo := &fakeRefreshTokenRepo{
+			stored: &domain.RefreshToken{
+				ID:        1,
+				UserID:    7,
+				TokenHash: hashToken("refresh-token"),
+				FamilyID:  "family",
+				ExpiresAt: tt.expiresAt,
+				RevokedAt: tt.revokedAt,
+			},
+			lostRace: tt.lostRace,
+		}
+		sessionRepo := &fakeSessionRepo{}
+		revocationRepo := &fakeRevocationRepo{}
+		s := &authService{refreshTokenRepo: refreshRepo, sessionRepo: sessionRepo, revocationRepo: revocationRepo}
+
+		_, err := s.Refresh(&domain.RefreshRequest{RefreshToken: "refresh-token"}, domain.ClientInfo{})
+		if !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Errorf("%s: error = %v, want ErrInvalidRefreshToken", tt.name, err)
+		}
+
+		// Reuse ends the whole session: its refresh tokens, its record and
+		// the access tokens issued for it
+		var want []string
+		if tt.wantRevoke {
+			want = []string{"family"}
+		}
+		if !slices.Equal(refreshRepo.revokedFamilies, want) {
+			t.Errorf("%s: revoked families = %v, want %v", tt.name, refreshRepo.revokedFamilies, want)
+		}
+		if !slices.Equal(sessionRepo.revoked, want) {
+			t.Errorf("%s: ended sessions = %v, want %v", tt.name, sessionRepo.revoked, want)
+		}
+		if !slices.Equal(revocationRepo.revoked, want) {
+			t.Errorf("%s: revoked access tokens = %v, want %v", tt.name, revocationRepo.revoked, want)
+		}
+	}
+}
+
+func TestRefreshRejectsUnknownToken(t *testing.T) {
+	refreshRepo := &fakeRefreshTokenRepo{}
+	s := &authService{refreshTokenRepo: refreshRepo}
+
+	_, err := s.Refresh(&domain.RefreshRequest{RefreshToken: "unknown"}, domain.ClientInfo{})
+	if !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("error = %v, want ErrInvalidRefreshToken", err)
+	}
+	if len(refreshRepo.revokedFamilies) != 0 {
+		t.Errorf("revoked families = %v, want none", refreshRepo.revokedFamilies)
+	}
+}
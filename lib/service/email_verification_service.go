@@ -0,0 +1,181 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"dummy-backend/lib/domain"
+	"dummy-backend/lib/repository"
+	"dummy-backend/pkg/config"
+	"dummy-backend/pkg/mailer"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification link")
+	ErrAlreadyVerified          = errors.New("email already verified")
+)
+
+type EmailVerificationService interface {
+	SendVerification(user *domain.User) error
+	Verify(token string) error
+	Resend(userID uint) error
+	// MarkVerified records that the user proved they own their address.
+	// Addresses listed in ADMIN_EMAILS are given the admin role at this point
+	// and never again, so a demoted admin stays demoted.
+	MarkVerified(user *domain.User) error
+}
+
+type emailVerificationService struct {
+	userRepo       repository.UserRepository
+	mailer         mailer.Mailer
+	key            []byte
+	baseURL        string
+	tokenTTL       time.Duration
+	resendInterval time.Duration
+	adminEmails    map[string]bool
+}
+
+func NewEmailVerificationService(userRepo repository.UserRepository, m mailer.Mailer, cfg *config.Config) EmailVerificationService {
+	// Derive a dedicated key so a verification link can never pass as a JWT
+	mac := hmac.New(sha256.New, []byte(cfg.JWTSecret))
+	mac.Write([]byte("email-verification"))
+
+	adminEmails := make(map[string]bool, len(cfg.AdminEmails))
+	for _, email := range cfg.AdminEmails {
+		adminEmails[domain.NormalizeEmail(email)] = true
+	}
+
+	return &emailVerificationService{
+		userRepo:       userRepo,
+		mailer:         m,
+		key:            mac.Sum(nil),
+		baseURL:        cfg.AppBaseURL,
+		tokenTTL:       cfg.EmailVerificationTTL,
+		resendInterval: cfg.VerificationResendInterval,
+		adminEmails:    adminEmails,
+	}
+}
+
+// verificationPayload is signed into the link. Binding the address means
+// a link stops working once the user changes their email.
+type verificationPayload struct {
+	UserID    uint   `json:"uid"`
+	Email     string `json:"email"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+func (s *emailVerificationService) SendVerification(user *domain.User) error {
+	token, err := s.sign(verificationPayload{
+		UserID:    user.ID,
+		Email:     user.Email,
+		ExpiresAt: time.Now().Add(s.tokenTTL).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := s.baseURL + "/verify-email?token=" + url.QueryEscape(token)
+	err = s.mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Welcome! Please confirm your email address by opening the link below within %s:\n\n%s\n",
+			s.tokenTTL, link),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.userRepo.SetVerificationSentAt(user.ID, time.Now())
+}
+
+func (s *emailVerificationService) Verify(token string) error {
+	payload, err := s.parse(token)
+	if err != nil || time.Now().Unix() > payload.ExpiresAt {
+		return ErrInvalidVerificationToken
+	}
+
+	user, err := s.userRepo.GetByID(payload.UserID)
+	if err != nil || user.Email != payload.Email {
+		return ErrInvalidVerificationToken
+	}
+	return s.MarkVerified(user)
+}
+
+func (s *emailVerificationService) MarkVerified(user *domain.User) error {
+	if user.VerifiedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	if err := s.userRepo.MarkVerified(user.ID, now); err != nil {
+		return err
+	}
+	user.VerifiedAt = &now
+
+	if s.adminEmails[user.Email] && user.Role != domain.RoleAdmin {
+		if err := s.userRepo.SetRole(user.ID, domain.RoleAdmin); err != nil {
+			return err
+		}
+		user.Role = domain.RoleAdmin
+	}
+	return nil
+}
+
+func (s *emailVerificationService) Resend(userID uint) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	if user.VerifiedAt != nil {
+		return ErrAlreadyVerified
+	}
+	if user.VerificationSentAt != nil {
+		if wait := time.Until(user.VerificationSentAt.Add(s.resendInterval)); wait > 0 {
+			return &ThrottledError{RetryAfter: wait}
+		}
+	}
+
+	return s.SendVerification(user)
+}
+
+func (s *emailVerificationService) sign(payload verificationPayload) (string, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	body := base64.RawURLEncoding.EncodeToString(raw)
+	return body + "." + base64.RawURLEncoding.EncodeToString(s.mac(body)), nil
+}
+
+func (s *emailVerificationService) parse(token string) (*verificationPayload, error) {
+	body, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidVerificationToken
+	}
+	expected, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(expected, s.mac(body)) {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return nil, err
+	}
+	var payload verificationPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
+
+func (s *emailVerificationService) mac(body string) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(body))
+	return mac.Sum(nil)
+}
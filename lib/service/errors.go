@@ -0,0 +1,58 @@
+package service
+
+import (
+	"dummy-backend/lib/domain"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// ThrottledError is returned when an action is attempted again too soon
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+// RetryAfterSeconds rounds RetryAfter up for use in a Retry-After header
+func (e *ThrottledError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("too many requests, retry in %d seconds", e.RetryAfterSeconds())
+}
+
+// PasswordPolicyError lists every policy rule a new password breaks
+type PasswordPolicyError struct {
+	Violations []domain.PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return "password does not meet the policy: " + strings.Join(messages, "; ")
+}
+
+// StatusTransitionError is returned when the task workflow does not allow
+// moving a task from one status to another
+type StatusTransitionError struct {
+	From    string
+	To      string
+	Allowed []string
+}
+
+func (e *StatusTransitionError) Error() string {
+	return fmt.Sprintf("cannot move a task from %s to %s", e.From, e.To)
+}
+
+// BlockingSubtasksError is returned when a task cannot be completed because
+// the workflow does not let some of its unfinished subtasks move to done
+type BlockingSubtasksError struct {
+	TaskIDs []uint
+}
+
+func (e *BlockingSubtasksError) Error() string {
+	return "the workflow does not allow some subtasks to move to done"
+}
@@ -0,0 +1,376 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"dummy-backend/lib/domain"
+	"dummy-backend/lib/repository"
+	"dummy-backend/pkg/config"
+	"dummy-backend/pkg/keys"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// keyReloadInterval is how often managed keys are re-read from the
+	// database to pick up rotations made by other instances
+	keyReloadInterval = time.Minute
+	// unknownKidReloadInterval rate-limits reloads triggered by tokens
+	// signed with a key this instance has not seen yet
+	unknownKidReloadInterval = 10 * time.Second
+)
+
+// KeyManager signs and verifies JWTs and publishes the verification keys
+type KeyManager interface {
+	Sign(claims jwt.MapClaims) (string, error)
+	// Keyfunc is passed to jwt.Parse to select the verification key
+	Keyfunc(token *jwt.Token) (interface{}, error)
+	JWKS() (*keys.JSONWebKeySet, error)
+}
+
+// NewKeyManager returns a key manager for cfg.JWTAlgorithm. HS256 signs with
+// the shared secret. RS256 and EdDSA use the configured keys if there are
+// any, otherwise keys generated and rotated through signingKeyRepo.
+func NewKeyManager(signingKeyRepo repository.SigningKeyRepository, cfg *config.Config) (KeyManager, error) {
+	switch cfg.JWTAlgorithm {
+	case "HS256":
+		return &hmacKeyManager{secret: []byte(cfg.JWTSecret)}, nil
+	case keys.RS256, keys.EdDSA:
+	default:
+		return nil, fmt.Errorf("unsupported JWT_ALGORITHM %q", cfg.JWTAlgorithm)
+	}
+
+	if len(cfg.JWTPrivateKeys) > 0 {
+		return newStaticKeyManager(cfg.JWTPrivateKeys, cfg.JWTPublicKeys)
+	}
+
+	return &managedKeyManager{
+		repo:             signingKeyRepo,
+		algorithm:        cfg.JWTAlgorithm,
+		encryptionKey:    deriveKey(cfg.JWTSecret, "signing-key-encryption"),
+		rotationInterval: cfg.JWTKeyRotationInterval,
+		// Keep retired keys until every token they signed has expired
+		retention: cfg.AccessTokenTTL + challengeTTL + keyReloadInterval,
+		extra:     cfg.JWTPublicKeys,
+	}, nil
+}
+
+type hmacKeyManager struct {
+	secret []byte
+}
+
+func (m *hmacKeyManager) Sign(claims jwt.MapClaims) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+}
+
+func (m *hmacKeyManager) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, ErrInvalidToken
+	}
+	return m.secret, nil
+}
+
+// JWKS is empty because a shared secret must never be published
+func (m *hmacKeyManager) JWKS() (*keys.JSONWebKeySet, error) {
+	return &keys.JSONWebKeySet{Keys: []keys.JSONWebKey{}}, nil
+}
+
+// keySet is an immutable snapshot of the signing key and every key that
+// may verify a token
+type keySet struct {
+	signing *keys.Key
+	verify  map[string]*keys.Key
+	order   []string // kids, signing key first
+}
+
+func (ks *keySet) add(key *keys.Key) {
+	if _, ok := ks.verify[key.ID]; ok {
+		return
+	}
+	ks.verify[key.ID] = key
+	ks.order = append(ks.order, key.ID)
+}
+
+func (ks *keySet) sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(ks.signing.Algorithm), claims)
+	token.Header["kid"] = ks.signing.ID
+	return token.SignedString(ks.signing.Private)
+}
+
+func (ks *keySet) lookup(token *jwt.Token) (*keys.Key, bool) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.verify[kid]
+	return key, ok
+}
+
+func (ks *keySet) jwks() *keys.JSONWebKeySet {
+	set := &keys.JSONWebKeySet{Keys: make([]keys.JSONWebKey, 0, len(ks.order))}
+	for _, kid := range ks.order {
+		set.Keys = append(set.Keys, ks.verify[kid].JWK())
+	}
+	return set
+}
+
+func verificationKey(key *keys.Key, token *jwt.Token) (interface{}, error) {
+	// The algorithm is pinned by the key, never taken from the token alone
+	if token.Method.Alg() != key.Algorithm {
+		return nil, ErrInvalidToken
+	}
+	return key.Public, nil
+}
+
+// staticKeyManager uses keys from configuration. Operators rotate by
+// putting a new key first and keeping the old one listed until the tokens
+// it signed have expired.
+type staticKeyManager struct {
+	set *keySet
+}
+
+func newStaticKeyManager(privateKeys, publicKeys []string) (*staticKeyManager, error) {
+	set := &keySet{verify: make(map[string]*keys.Key)}
+	for _, source := range privateKeys {
+		key, err := loadKey(source, keys.ParsePrivateKeyPEM)
+		if err != nil {
+			return nil, err
+		}
+		if set.signing == nil {
+			set.signing = key
+		}
+		set.add(key)
+	}
+	for _, source := range publicKeys {
+		key, err := loadKey(source, keys.ParsePublicKeyPEM)
+		if err != nil {
+			return nil, err
+		}
+		set.add(key)
+	}
+	return &staticKeyManager{set: set}, nil
+}
+
+func (m *staticKeyManager) Sign(claims jwt.MapClaims) (string, error) {
+	return m.set.sign(claims)
+}
+
+func (m *staticKeyManager) Keyfunc(token *jwt.Token) (interface{}, error) {
+	key, ok := m.set.lookup(token)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	return verificationKey(key, token)
+}
+
+func (m *staticKeyManager) JWKS() (*keys.JSONWebKeySet, error) {
+	return m.set.jwks(), nil
+}
+
+// managedKeyManager generates signing keys and stores them in the database
+// so that all instances share them. Rotation happens lazily: the first
+// instance to notice that the newest key is older than rotationInterval
+// creates a replacement. If two instances race, both keys are valid and the
+// newer one wins on the next reload.
+type managedKeyManager struct {
+	repo             repository.SigningKeyRepository
+	algorithm        string
+	encryptionKey    []byte
+	rotationInterval time.Duration
+	retention        time.Duration
+	extra            []string // verification-only public keys from config
+
+	mu        sync.Mutex
+	set       *keySet
+	createdAt time.Time // of the signing key
+	loadedAt  time.Time
+}
+
+func (m *managedKeyManager) Sign(claims jwt.MapClaims) (string, error) {
+	set, err := m.current(false)
+	if err != nil {
+		return "", err
+	}
+	return set.sign(claims)
+}
+
+func (m *managedKeyManager) Keyfunc(token *jwt.Token) (interface{}, error) {
+	set, err := m.current(false)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := set.lookup(token)
+	if !ok {
+		// Possibly signed with a key another instance just created
+		if set, err = m.current(true); err != nil {
+			return nil, err
+		}
+		if key, ok = set.lookup(token); !ok {
+			return nil, ErrInvalidToken
+		}
+	}
+	return verificationKey(key, token)
+}
+
+func (m *managedKeyManager) JWKS() (*keys.JSONWebKeySet, error) {
+	set, err := m.current(false)
+	if err != nil {
+		return nil, err
+	}
+	return set.jwks(), nil
+}
+
+// current returns the key set, reloading it when it is stale, when the
+// signing key is due for rotation, or when force is set (rate-limited).
+func (m *managedKeyManager) current(force bool) (*keySet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if m.set != nil {
+		fresh := now.Sub(m.loadedAt) < keyReloadInterval && now.Sub(m.createdAt) < m.rotationInterval
+		if force {
+			fresh = now.Sub(m.loadedAt) < unknownKidReloadInterval
+		}
+		if fresh {
+			return m.set, nil
+		}
+	}
+
+	cutoff := now.Add(-m.rotationInterval - m.retention)
+	stored, err := m.repo.ListCreatedAfter(cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(stored) == 0 || now.Sub(stored[0].CreatedAt) >= m.rotationInterval {
+		created, err := m.generate()
+		if err != nil {
+			return nil, err
+		}
+		stored = append([]domain.SigningKey{*created}, stored...)
+		if err := m.repo.DeleteCreatedBefore(cutoff); err != nil {
+			return nil, err
+		}
+	}
+
+	set := &keySet{verify: make(map[string]*keys.Key)}
+	for i := range stored {
+		key, err := m.decrypt(&stored[i])
+		if err != nil {
+			return nil, err
+		}
+		if set.signing == nil {
+			set.signing = key
+		}
+		set.add(key)
+	}
+	for _, source := range m.extra {
+		key, err := loadKey(source, keys.ParsePublicKeyPEM)
+		if err != nil {
+			return nil, err
+		}
+		set.add(key)
+	}
+
+	m.set = set
+	m.createdAt = stored[0].CreatedAt
+	m.loadedAt = now
+	return set, nil
+}
+
+func (m *managedKeyManager) generate() (*domain.SigningKey, error) {
+	key, err := keys.Generate(m.algorithm)
+	if err != nil {
+		return nil, err
+	}
+	pemBytes, err := key.MarshalPrivateKeyPEM()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := encrypt(m.encryptionKey, pemBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := &domain.SigningKey{
+		KID:        key.ID,
+		Algorithm:  key.Algorithm,
+		PrivateKey: encrypted,
+		CreatedAt:  time.Now(),
+	}
+	if err := m.repo.Create(stored); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+func (m *managedKeyManager) decrypt(stored *domain.SigningKey) (*keys.Key, error) {
+	pemBytes, err := decrypt(m.encryptionKey, stored.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting signing key %s (was JWT_SECRET changed?): %w", stored.KID, err)
+	}
+	return keys.ParsePrivateKeyPEM(pemBytes)
+}
+
+// loadKey parses source as inline PEM or, failing that, as a file path
+func loadKey(source string, parse func([]byte) (*keys.Key, error)) (*keys.Key, error) {
+	data := []byte(source)
+	if !strings.HasPrefix(source, "-----BEGIN") {
+		var err error
+		if data, err = os.ReadFile(source); err != nil {
+			return nil, err
+		}
+	}
+	key, err := parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("loading key %.40q: %w", source, err)
+	}
+	return key, nil
+}
+
+func deriveKey(secret, purpose string) []byte {
+	sum := sha256.Sum256([]byte(purpose + ":" + secret))
+	return sum[:]
+}
+
+func encrypt(key, plaintext []byte) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+func decrypt(key []byte, encoded string) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, raw[:gcm.NonceSize()], raw[gcm.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
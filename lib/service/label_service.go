@@ -0,0 +1,132 @@
+package service
+
+import (
+	"dummy-backend/lib/domain"
+	"dummy-backend/lib/repository"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrLabelNotFound    = errors.New("label not found")
+	ErrLabelNameTaken   = errors.New("a label with this name already exists")
+	ErrInvalidLabelName = errors.New("label name must not be blank")
+	ErrUnknownTaskLabel = errors.New("unknown label")
+)
+
+// LabelService manages a user's labels. Like tasks, labels owned by someone
+// else are reported as not found.
+type LabelService interface {
+	CreateLabel(userID uint, req *domain.CreateLabelRequest) (*domain.Label, error)
+	ListLabels(userID uint) ([]domain.Label, error)
+	GetLabel(userID, id uint) (*domain.Label, error)
+	UpdateLabel(userID, id uint, req *domain.UpdateLabelRequest) (*domain.Label, error)
+	DeleteLabel(userID, id uint) error
+}
+
+type labelService struct {
+	labelRepo repository.LabelRepository
+}
+
+func NewLabelService(labelRepo repository.LabelRepository) LabelService {
+	return &labelService{labelRepo: labelRepo}
+}
+
+func (s *labelService) CreateLabel(userID uint, req *domain.CreateLabelRequest) (*domain.Label, error) {
+	name := strings.TrimSpace(req.Name)
+	if err := s.checkNameFree(userID, 0, name); err != nil {
+		return nil, err
+	}
+
+	label := &domain.Label{UserID: userID, Name: name, Color: strings.ToLower(req.Color)}
+	if err := s.labelRepo.Create(label); err != nil {
+		return nil, err
+	}
+	return label, nil
+}
+
+func (s *labelService) ListLabels(userID uint) ([]domain.Label, error) {
+	labels, err := s.labelRepo.List(userID)
+	if err != nil {
+		return nil, err
+	}
+	if labels == nil {
+		labels = []domain.Label{}
+	}
+	return labels, nil
+}
+
+func (s *labelService) GetLabel(userID, id uint) (*domain.Label, error) {
+	label, err := s.labelRepo.GetByID(userID, id)
+	if err != nil {
+		return nil, ErrLabelNotFound
+	}
+	return label, nil
+}
+
+func (s *labelService) UpdateLabel(userID, id uint, req *domain.UpdateLabelRequest) (*domain.Label, error) {
+	label, err := s.labelRepo.GetByID(userID, id)
+	if err != nil {
+		return nil, ErrLabelNotFound
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if err := s.checkNameFree(userID, id, name); err != nil {
+			return nil, err
+		}
+		label.Name = name
+	}
+	if req.Color != nil {
+		label.Color = strings.ToLower(*req.Color)
+	}
+
+	if err := s.labelRepo.Update(label); err != nil {
+		return nil, err
+	}
+	return label, nil
+}
+
+func (s *labelService) DeleteLabel(userID, id uint) error {
+	if _, err := s.labelRepo.GetByID(userID, id); err != nil {
+		return ErrLabelNotFound
+	}
+	return s.labelRepo.Delete(userID, id)
+}
+
+// checkNameFree fails if another of the user's labels than id has name
+func (s *labelService) checkNameFree(userID, id uint, name string) error {
+	if name == "" {
+		return ErrInvalidLabelName
+	}
+	existing, err := s.labelRepo.GetByName(userID, name)
+	if err == nil && existing.ID != id {
+		return ErrLabelNameTaken
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return nil
+}
+
+// resolveLabels loads the labels with ids, failing if any of them is not one
+// of the user's labels
+func resolveLabels(labelRepo repository.LabelRepository, userID uint, ids []uint) ([]domain.Label, error) {
+	if len(ids) == 0 {
+		return []domain.Label{}, nil
+	}
+	labels, err := labelRepo.GetByIDs(userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if !slices.ContainsFunc(labels, func(l domain.Label) bool { return l.ID == id }) {
+			return nil, fmt.Errorf("%w %d", ErrUnknownTaskLabel, id)
+		}
+	}
+	return labels, nil
+}
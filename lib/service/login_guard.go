@@ -0,0 +1,148 @@
+package service
+
+import (
+	"dummy-backend/lib/domain"
+	"dummy-backend/lib/repository"
+	"dummy-backend/pkg/config"
+	"fmt"
+	"log"
+	"time"
+)
+
+// LoginGuard throttles password and two-factor guessing. Failures are
+// counted per account and per client IP. Each failure makes the next
+// attempt wait exponentially longer, and reaching the threshold locks the
+// key out for a fixed period.
+type LoginGuard interface {
+	// Check returns a *ThrottledError if the attempt must be refused
+	Check(email, ip string) error
+	RecordFailure(email, ip string) error
+	RecordSuccess(email string) error
+}
+
+type loginGuard struct {
+	store              repository.LoginAttemptStore
+	auditRepo          repository.AuditRepository
+	maxAccountFailures int
+	maxIPFailures      int
+	lockoutDuration    time.Duration
+	failureWindow      time.Duration
+	backoffBase        time.Duration
+	backoffMax         time.Duration
+}
+
+func NewLoginGuard(store repository.LoginAttemptStore, auditRepo repository.AuditRepository, cfg *config.Config) LoginGuard {
+	return &loginGuard{
+		store:              store,
+		auditRepo:          auditRepo,
+		maxAccountFailures: cfg.LoginMaxAccountFailures,
+		maxIPFailures:      cfg.LoginMaxIPFailures,
+		lockoutDuration:    cfg.LoginLockoutDuration,
+		failureWindow:      cfg.LoginFailureWindow,
+		backoffBase:        cfg.LoginBackoffBase,
+		backoffMax:         cfg.LoginBackoffMax,
+	}
+}
+
+func accountKey(email string) string {
+	return "account:" + domain.NormalizeEmail(email)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func (g *loginGuard) Check(email, ip string) error {
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range []string{accountKey(email), ipKey(ip)} {
+		attempt, err := g.store.Get(key)
+		if err != nil {
+			return err
+		}
+		if attempt == nil {
+			continue
+		}
+
+		// A lockout may outlast the failure window
+		if attempt.LockedUntil != nil {
+			if d := attempt.LockedUntil.Sub(now); d > wait {
+				wait = d
+			}
+		}
+		if attempt.LastFailureAt.Before(now.Add(-g.failureWindow)) {
+			continue
+		}
+		if d := attempt.LastFailureAt.Add(g.backoff(attempt.Failures)).Sub(now); d > wait {
+			wait = d
+		}
+	}
+
+	if wait > 0 {
+		return &ThrottledError{RetryAfter: wait}
+	}
+	return nil
+}
+
+func (g *loginGuard) RecordFailure(email, ip string) error {
+	now := time.Now()
+	windowStart := now.Add(-g.failureWindow)
+
+	keys := []struct {
+		key string
+		max int
+	}{
+		{accountKey(email), g.maxAccountFailures},
+		{ipKey(ip), g.maxIPFailures},
+	}
+	for _, k := range keys {
+		attempt, err := g.store.RecordFailure(k.key, now, windowStart)
+		if err != nil {
+			return err
+		}
+		// Lock when the threshold is reached, and again if failures go on
+		// after a lockout shorter than the failure window has run out
+		if attempt.Failures < k.max || (attempt.LockedUntil != nil && attempt.LockedUntil.After(now)) {
+			continue
+		}
+
+		until := now.Add(g.lockoutDuration)
+		if err := g.store.Lock(k.key, until); err != nil {
+			return err
+		}
+		details := fmt.Sprintf("locked until %s after %d failed attempts", until.Format(time.RFC3339), attempt.Failures)
+		log.Printf("Login lockout for %s: %s", k.key, details)
+		err = g.auditRepo.Create(&domain.AuditEvent{
+			Action:  domain.AuditLoginLockout,
+			Subject: k.key,
+			IP:      ip,
+			Details: details,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordSuccess clears the account's counter. The IP counter is left alone
+// so that one valid account cannot be used to reset guessing against others.
+func (g *loginGuard) RecordSuccess(email string) error {
+	return g.store.Reset(accountKey(email))
+}
+
+// backoff returns the delay required after the given number of failures:
+// nothing after the first, then base, 2*base, 4*base... up to backoffMax
+func (g *loginGuard) backoff(failures int) time.Duration {
+	if failures < 2 {
+		return 0
+	}
+	d := g.backoffBase
+	for i := 2; i < failures && d < g.backoffMax; i++ {
+		d *= 2
+	}
+	if d > g.backoffMax {
+		d = g.backoffMax
+	}
+	return d
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"dummy-backend/lib/domain"
+	"dummy-backend/lib/repository"
+	"dummy-backend/pkg/config"
+	"errors"
+	"testing"
+	"time"
+)
+
+// recordingAuditRepo keeps created events in memory
+type recordingAuditRepo struct {
+	repository.AuditRepository
+	events []domain.AuditEvent
+}
+
+func (r *recordingAuditRepo) Create(event *domain.AuditEvent) error {
+	r.events = append(r.events, *event)
+	return nil
+}
+
+func newTestLoginGuard(lockout, window time.Duration) (*loginGuard, repository.LoginAttemptStore, *recordingAuditRepo) {
+	store := repository.NewMemoryLoginAttemptStore()
+	audit := &recordingAuditRepo{}
+	guard := NewLoginGuard(store, audit, &config.Config{
+		LoginMaxAccountFailures: 3,
+		LoginMaxIPFailures:      10,
+		LoginLockoutDuration:    lockout,
+		LoginFailureWindow:      window,
+		LoginBackoffBase:        time.Second,
+		LoginBackoffMax:         30 * time.Second,
+	})
+	return guard.(*loginGuard), store, audit
+}
+
+func retryAfter(t *testing.T, err error) time.Duration {
+	t.Helper()
+	var throttled *ThrottledError
+	if !errors.As(err, &throttled) {
+		t.Fatalf("error = %v, want *ThrottledError", err)
+	}
+	return throttled.RetryAfter
+}
+
+func TestLoginGuardBackoff(t *testing.T) {
+	guard, _, _ := newTestLoginGuard(time.Hour, time.Hour)
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, 0},
+		{2, time.Second},
+		{3, 2 * time.Second},
+		{4, 4 * time.Second},
+		{6, 16 * time.Second},
+		{7, 30 * time.Second},
+		{50, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := guard.backoff(tt.failures); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginGuardLocksAccount(t *testing.T) {
+	guard, _, audit := newTestLoginGuard(15*time.Minute, time.Hour)
+
+	if err := guard.Check("user@example.com", "10.0.0.1"); err != nil {
+		t.Fatalf("Check before any failure: %v", err)
+	}
+	// Each failure comes from another IP so only the account counter locks
+	for i, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		if err := guard.RecordFailure("user@example.com", ip); err != nil {
+			t.Fatalf("RecordFailure %d: %v", i+1, err)
+		}
+	}
+
+	if wait := retryAfter(t, guard.Check("user@example.com", "10.0.0.4")); wait < 14*time.Minute {
+		t.Errorf("RetryAfter = %v, want about the lockout duration", wait)
+	}
+	if len(audit.events) != 1 || audit.events[0].Action != domain.AuditLoginLockout || audit.events[0].Subject != "account:user@example.com" {
+		t.Errorf("audit events = %+v, want one lockout of the account", audit.events)
+	}
+	if err := guard.Check("other@example.com", "10.0.0.4"); err != nil {
+		t.Errorf("Check for another account: %v", err)
+	}
+}
+
+func TestLoginGuardNormalizesEmail(t *testing.T) {
+	guard, _, _ := newTestLoginGuard(15*time.Minute, time.Hour)
+
+	for i, email := range []string{"user@example.com", "User@Example.com", " USER@example.com "} {
+		if err := guard.RecordFailure(email, "10.0.0.1"); err != nil {
+			t.Fatalf("RecordFailure %d: %v", i+1, err)
+		}
+	}
+
+	if wait := retryAfter(t, guard.Check("user@EXAMPLE.com", "10.0.0.2")); wait < 14*time.Minute {
+		t.Errorf("RetryAfter = %v, want about the lockout duration", wait)
+	}
+}
+
+func TestLoginGuardLockoutOutlastsWindow(t *testing.T) {
+	guard, store, _ := newTestLoginGuard(time.Hour, time.Minute)
+	key := accountKey("user@example.com")
+
+	// Failures that have left the window, with the lockout they caused
+	old := time.Now().Add(-10 * time.Minute)
+	for i := 0; i < 3; i++ {
+		if _, err := store.RecordFailure(key, old, old.Add(-time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Lock(key, old.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if wait := retryAfter(t, guard.Check("user@example.com", "10.0.0.1")); wait < 49*time.Minute {
+		t.Errorf("RetryAfter = %v, want the rest of the lockout", wait)
+	}
+}
+
+func TestLoginGuardLocksAgainAfterShortLockout(t *testing.T) {
+	guard, store, audit := newTestLoginGuard(time.Minute, time.Hour)
+	key := accountKey("user@example.com")
+
+	for i := 0; i < 3; i++ {
+		if err := guard.RecordFailure("user@example.com", "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The lockout runs out while the failures are still inside the window
+	if err := store.Lock(key, time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := guard.RecordFailure("user@example.com", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	attempt, _ := store.Get(key)
+	if attempt.LockedUntil == nil || !attempt.LockedUntil.After(time.Now()) {
+		t.Fatalf("LockedUntil = %v after failing past the threshold, want a new lockout", attempt.LockedUntil)
+	}
+
+	// Failures during a lockout do not extend it
+	locked := *attempt.LockedUntil
+	if err := guard.RecordFailure("user@example.com", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if attempt, _ := store.Get(key); !attempt.LockedUntil.Equal(locked) {
+		t.Errorf("LockedUntil moved from %v to %v during a lockout", locked, attempt.LockedUntil)
+	}
+	if len(audit.events) != 2 {
+		t.Errorf("got %d lockout events, want 2", len(audit.events))
+	}
+}
+
+func TestLoginGuardSuccessKeepsIPCounter(t *testing.T) {
+	guard, store, _ := newTestLoginGuard(15*time.Minute, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if err := guard.RecordFailure("user@example.com", "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := guard.RecordSuccess("User@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if attempt, _ := store.Get(accountKey("user@example.com")); attempt != nil {
+		t.Errorf("account counter = %+v after a success, want none", attempt)
+	}
+	if attempt, _ := store.Get(ipKey("10.0.0.1")); attempt == nil || attempt.Failures != 2 {
+		t.Errorf("IP counter = %+v after a success, want 2 failures", attempt)
+	}
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"dummy-backend/lib/domain"
+	"dummy-backend/lib/repository"
+	"dummy-backend/pkg/config"
+	"dummy-backend/pkg/mailer"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+)
+
+var ErrInvalidMagicLink = errors.New("invalid or expired login link")
+
+type MagicLinkService interface {
+	// SendLink emails a login link if the address belongs to a user. It
+	// reports success either way so callers cannot probe for accounts, and
+	// throttles every address alike.
+	SendLink(req *domain.MagicLinkRequest) error
+	// Login exchanges a link token for the same response as a password login
+	Login(token string, client domain.ClientInfo) (*domain.AuthResponse, error)
+}
+
+type magicLinkService struct {
+	userRepo      repository.UserRepository
+	magicLinkRepo repository.MagicLinkRepository
+	attempts      repository.LoginAttemptStore
+	authService   AuthService
+	verification  EmailVerificationService
+	mailer        mailer.Mailer
+	baseURL       string
+	tokenTTL      time.Duration
+	rateLimit     int
+	rateWindow    time.Duration
+}
+
+func NewMagicLinkService(userRepo repository.UserRepository, magicLinkRepo repository.MagicLinkRepository, attempts repository.LoginAttemptStore, authService AuthService, verification EmailVerificationService, m mailer.Mailer, cfg *config.Config) MagicLinkService {
+	return &magicLinkService{
+		userRepo:      userRepo,
+		magicLinkRepo: magicLinkRepo,
+		attempts:      attempts,
+		authService:   authService,
+		verification:  verification,
+		mailer:        m,
+		baseURL:       cfg.AppBaseURL,
+		tokenTTL:      cfg.MagicLinkTTL,
+		rateLimit:     cfg.MagicLinkRateLimit,
+		rateWindow:    cfg.MagicLinkRateWindow,
+	}
+}
+
+func (s *magicLinkService) SendLink(req *domain.MagicLinkRequest) error {
+	if err := s.throttle(req.Email); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetByEmail(req.Email)
+	if err != nil {
+		return nil
+	}
+
+	// Only the most recent link should work
+	if err := s.magicLinkRepo.InvalidateForUser(user.ID); err != nil {
+		return err
+	}
+
+	token, hash, err := generateOpaqueToken()
+	if err != nil {
+		return err
+	}
+	err = s.magicLinkRepo.Create(&domain.MagicLinkToken{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(s.tokenTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := s.baseURL + "/magic-link?token=" + url.QueryEscape(token)
+	err = s.mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: "Your login link",
+		Body: fmt.Sprintf("Open the link below within %s to log in:\n\n%s\n\n"+
+			"If you didn't ask for this, you can ignore this email.\n", s.tokenTTL, link),
+	})
+	if err != nil {
+		// Failing the request would reveal that the account exists
+		log.Printf("Failed to send login link to user %d: %v", user.ID, err)
+	}
+
+	return nil
+}
+
+// throttle counts the request against the address. The login attempt store
+// is reused as a shared counter so the limit holds across instances.
+func (s *magicLinkService) throttle(email string) error {
+	key := "magic-link:" + domain.NormalizeEmail(email)
+	now := time.Now()
+
+	attempt, err := s.attempts.Get(key)
+	if err != nil {
+		return err
+	}
+	if attempt != nil && attempt.Failures >= s.rateLimit {
+		if wait := attempt.LastFailureAt.Add(s.rateWindow).Sub(now); wait > 0 {
+			return &ThrottledError{RetryAfter: wait}
+		}
+	}
+
+	_, err = s.attempts.RecordFailure(key, now, now.Add(-s.rateWindow))
+	return err
+}
+
+func (s *magicLinkService) Login(token string, client domain.ClientInfo) (*domain.AuthResponse, error) {
+	stored, err := s.magicLinkRepo.GetByHash(hashToken(token))
+	if err != nil {
+		return nil, ErrInvalidMagicLink
+	}
+	if stored.UsedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return nil, ErrInvalidMagicLink
+	}
+
+	consumed, err := s.magicLinkRepo.MarkUsed(stored.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, ErrInvalidMagicLink
+	}
+
+	user, err := s.userRepo.GetByID(stored.UserID)
+	if err != nil {
+		return nil, ErrInvalidMagicLink
+	}
+
+	// Opening the link proves the user owns the address
+	if err := claimUnverified(user, s.userRepo, s.authService, s.verification); err != nil {
+		return nil, err
+	}
+
+	return s.authService.CompleteLogin(user, client)
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"dummy-backend/lib/domain"
+	"dummy-backend/lib/repository"
+	"dummy-backend/pkg/config"
+	"dummy-backend/pkg/oidc"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OIDCStateTTL is how long a provider login may take
+const OIDCStateTTL = 10 * time.Minute
+
+var (
+	ErrUnknownOIDCProvider  = errors.New("unknown login provider")
+	ErrInvalidOIDCState     = errors.New("invalid or expired login state")
+	ErrOIDCLoginFailed      = errors.New("login with provider failed")
+	ErrOIDCEmailNotVerified = errors.New("provider did not return a verified email address")
+)
+
+// OIDCService runs the OpenID Connect authorization code flow. The browser
+// is sent to AuthorizationURL and comes back to the frontend, which passes
+// code and state to Callback.
+type OIDCService interface {
+	Providers() []string
+	// Authorize also returns a binding secret, which the caller keeps in the
+	// browser and hands back to Callback
+	Authorize(ctx context.Context, provider string) (*domain.OIDCAuthorizeResponse, string, error)
+	Callback(ctx context.Context, req *domain.OIDCCallbackRequest, binding string, client domain.ClientInfo) (*domain.AuthResponse, error)
+}
+
+type oidcService struct {
+	providers    map[string]*oidc.Provider
+	stateRepo    repository.OIDCLoginStateRepository
+	identityRepo repository.UserIdentityRepository
+	userRepo     repository.UserRepository
+	authService  AuthService
+	verification EmailVerificationService
+}
+
+func NewOIDCService(stateRepo repository.OIDCLoginStateRepository, identityRepo repository.UserIdentityRepository, userRepo repository.UserRepository, authService AuthService, verification EmailVerificationService, cfg *config.Config) OIDCService {
+	providers := make(map[string]*oidc.Provider, len(cfg.OIDCProviders))
+	for _, p := range cfg.OIDCProviders {
+		providers[p.Name] = oidc.NewProvider(oidc.Config{
+			Name:         p.Name,
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			Scopes:       p.Scopes,
+		}, nil)
+	}
+
+	return &oidcService{
+		providers:    providers,
+		stateRepo:    stateRepo,
+		identityRepo: identityRepo,
+		userRepo:     userRepo,
+		authService:  authService,
+		verification: verification,
+	}
+}
+
+func (s *oidcService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *oidcService) Authorize(ctx context.Context, provider string) (*domain.OIDCAuthorizeResponse, string, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, "", ErrUnknownOIDCProvider
+	}
+
+	state, stateHash, err := generateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+	binding, bindingHash, err := generateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+	verifier, _, err := generateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+	nonce, err := randomID()
+	if err != nil {
+		return nil, "", err
+	}
+
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, oidc.CodeChallenge(verifier))
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = s.stateRepo.Create(&domain.OIDCLoginState{
+		StateHash:    stateHash,
+		BindingHash:  bindingHash,
+		Provider:     provider,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(OIDCStateTTL),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &domain.OIDCAuthorizeResponse{AuthorizationURL: authURL}, binding, nil
+}
+
+// Callback consumes the state even when the binding does not match, so a
+// leaked state can be tried only once
+func (s *oidcService) Callback(ctx context.Context, req *domain.OIDCCallbackRequest, binding string, client domain.ClientInfo) (*domain.AuthResponse, error) {
+	state, err := s.stateRepo.Consume(hashToken(req.State))
+	if err != nil || time.Now().After(state.ExpiresAt) {
+		return nil, ErrInvalidOIDCState
+	}
+	if binding == "" || subtle.ConstantTimeCompare([]byte(hashToken(binding)), []byte(state.BindingHash)) != 1 {
+		return nil, ErrInvalidOIDCState
+	}
+	p, ok := s.providers[state.Provider]
+	if !ok {
+		return nil, ErrUnknownOIDCProvider
+	}
+
+	claims, err := p.Exchange(ctx, req.Code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		log.Printf("OIDC login with %s failed: %v", state.Provider, err)
+		return nil, ErrOIDCLoginFailed
+	}
+
+	user, err := s.resolveUser(state.Provider, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.authService.CompleteLogin(user, client)
+}
+
+// resolveUser finds the user for a provider identity. Unknown identities are
+// linked to the account with the same email, or get a new account without a
+// local password. Both require the provider to vouch for the address.
+func (s *oidcService) resolveUser(provider string, claims *oidc.Claims) (*domain.User, error) {
+	identity, err := s.identityRepo.GetByProviderSubject(provider, claims.Subject)
+	if err == nil {
+		user, err := s.userRepo.GetByID(identity.UserID)
+		if err != nil {
+			return nil, ErrOIDCLoginFailed
+		}
+		return user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, ErrOIDCEmailNotVerified
+	}
+
+	user, err := s.userRepo.GetByEmail(claims.Email)
+	if err == nil {
+		if err := claimUnverified(user, s.userRepo, s.authService, s.verification); err != nil {
+			return nil, err
+		}
+	} else {
+		user = &domain.User{
+			Email:       claims.Email,
+			DisplayName: claims.Name,
+			Role:        domain.RoleUser,
+		}
+		if err := s.userRepo.Create(user); err != nil {
+			return nil, err
+		}
+		if err := s.verification.MarkVerified(user); err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.identityRepo.Create(&domain.UserIdentity{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"dummy-backend/lib/domain"
+	"dummy-backend/pkg/oidc"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// memoryStateRepo keeps login states in memory
+type memoryStateRepo struct {
+	mu     sync.Mutex
+	states map[string]domain.OIDCLoginState
+}
+
+func (r *memoryStateRepo) Create(state *domain.OIDCLoginState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.states[state.StateHash] = *state
+	return nil
+}
+
+func (r *memoryStateRepo) Consume(stateHash string) (*domain.OIDCLoginState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state, ok := r.states[stateHash]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	delete(r.states, stateHash)
+	return &state, nil
+}
+
+// newBindingTestService returns a service for a provider that publishes its
+// metadata but rejects every code, so a callback that gets past the binding
+// check ends in ErrOIDCLoginFailed
+func newBindingTestService(t *testing.T) *oidcService {
+	t.Helper()
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"jwks_uri":               server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+	})
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	provider := oidc.NewProvider(oidc.Config{
+		Name:         "mock",
+		Issuer:       server.URL,
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		RedirectURL:  "https://app.example.com/auth/callback",
+	}, server.Client())
+
+	return &oidcService{
+		providers: map[string]*oidc.Provider{"mock": provider},
+		stateRepo: &memoryStateRepo{states: make(map[string]domain.OIDCLoginState)},
+	}
+}
+
+func TestCallbackRequiresBinding(t *testing.T) {
+	tests := []struct {
+		name string
+		// binding maps the value Authorize returned to the one the browser
+		// presents
+		binding func(issued string) string
+		wantErr error
+	}{
+		{name: "matching binding", binding: func(issued string) string { return issued }, wantErr: ErrOIDCLoginFailed},
+		{name: "missing binding", binding: func(string) string { return "" }, wantErr: ErrInvalidOIDCState},
+		{name: "binding from another login", binding: func(string) string { return "other-binding" }, wantErr: ErrInvalidOIDCState},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newBindingTestService(t)
+			ctx := context.Background()
+
+			resp, binding, err := s.Authorize(ctx, "mock")
+			if err != nil {
+				t.Fatalf("Authorize: %v", err)
+			}
+			authURL, err := url.Parse(resp.AuthorizationURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := &domain.OIDCCallbackRequest{Code: "code", State: authURL.Query().Get("state")}
+
+			_, err = s.Callback(ctx, req, tt.binding(binding), domain.ClientInfo{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Callback error = %v, want %v", err, tt.wantErr)
+			}
+
+			// The state is spent either way
+			_, err = s.Callback(ctx, req, binding, domain.ClientInfo{})
+			if !errors.Is(err, ErrInvalidOIDCState) {
+				t.Fatalf("second Callback error = %v, want %v", err, ErrInvalidOIDCState)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"dummy-backend/lib/domain"
+	"dummy-backend/pkg/config"
+	"dummy-backend/pkg/passwords"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// bcryptMaxBytes is the length after which bcrypt ignores the input
+const bcryptMaxBytes = 72
+
+// minEmailPartLength keeps short local parts like "jo" from rejecting
+// passwords that merely contain those letters
+const minEmailPartLength = 3
+
+// PasswordPolicy decides whether a new password is acceptable. Validate
+// returns a *PasswordPolicyError listing every broken rule.
+type PasswordPolicy interface {
+	Validate(password, email string) error
+}
+
+type charClass struct {
+	rule    string
+	message string
+	matches func(rune) bool
+}
+
+var charClasses = map[string]charClass{
+	"lower":  {domain.PasswordRuleLowercase, "must contain a lowercase letter", unicode.IsLower},
+	"upper":  {domain.PasswordRuleUppercase, "must contain an uppercase letter", unicode.IsUpper},
+	"digit":  {domain.PasswordRuleDigit, "must contain a digit", unicode.IsDigit},
+	"symbol": {domain.PasswordRuleSymbol, "must contain a symbol", isSymbol},
+}
+
+type passwordPolicy struct {
+	minLength     int
+	maxBytes      int
+	classes       []charClass
+	checkBreached bool
+}
+
+func NewPasswordPolicy(cfg *config.Config) (PasswordPolicy, error) {
+	maxBytes := cfg.PasswordMaxLength
+	if maxBytes <= 0 || maxBytes > bcryptMaxBytes {
+		maxBytes = bcryptMaxBytes
+	}
+	if cfg.PasswordMinLength > maxBytes {
+		return nil, fmt.Errorf("PASSWORD_MIN_LENGTH %d exceeds the maximum length %d", cfg.PasswordMinLength, maxBytes)
+	}
+
+	policy := &passwordPolicy{
+		minLength:     cfg.PasswordMinLength,
+		maxBytes:      maxBytes,
+		checkBreached: cfg.PasswordCheckBreached,
+	}
+	for _, name := range cfg.PasswordRequiredClasses {
+		class, ok := charClasses[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown password character class %q, expected lower, upper, digit or symbol", name)
+		}
+		policy.classes = append(policy.classes, class)
+	}
+	return policy, nil
+}
+
+func (p *passwordPolicy) Validate(password, email string) error {
+	var violations []domain.PasswordViolation
+	add := func(rule, message string) {
+		violations = append(violations, domain.PasswordViolation{Rule: rule, Message: message})
+	}
+
+	if utf8.RuneCountInString(password) < p.minLength {
+		add(domain.PasswordRuleMinLength, fmt.Sprintf("must be at least %d characters long", p.minLength))
+	}
+	if len(password) > p.maxBytes {
+		add(domain.PasswordRuleMaxLength, fmt.Sprintf("must be at most %d bytes long", p.maxBytes))
+	}
+	for _, class := range p.classes {
+		if !strings.ContainsFunc(password, class.matches) {
+			add(class.rule, class.message)
+		}
+	}
+	if containsEmail(password, email) {
+		add(domain.PasswordRuleContainsEmail, "must not contain your email address")
+	}
+	if p.checkBreached && passwords.IsCommon(password) {
+		add(domain.PasswordRuleBreached, "is too common and appears in known data breaches")
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// containsEmail reports whether the password contains the address or its
+// local part, ignoring case
+func containsEmail(password, email string) bool {
+	if email == "" {
+		return false
+	}
+	password = strings.ToLower(password)
+	email = strings.ToLower(email)
+	local, _, _ := strings.Cut(email, "@")
+	return strings.Contains(password, email) ||
+		(len(local) >= minEmailPartLength && strings.Contains(password, local))
+}
+
+func isSymbol(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r)
+}
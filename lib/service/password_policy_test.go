@@ -0,0 +1,98 @@
+package service
+
+import (
+	"dummy-backend/lib/domain"
+	"dummy-backend/pkg/config"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// violatedRules returns the rules err reports, or nil if the password passed
+func violatedRules(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var policyErr *PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("error = %v, want *PasswordPolicyError", err)
+	}
+	rules := make([]string, len(policyErr.Violations))
+	for i, v := range policyErr.Violations {
+		rules[i] = v.Rule
+	}
+	return rules
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy, err := NewPasswordPolicy(&config.Config{
+		PasswordMinLength:       10,
+		PasswordMaxLength:       72,
+		PasswordRequiredClasses: []string{"lower", "Upper", "digit", "symbol"},
+		PasswordCheckBreached:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		email    string
+		want     []string
+	}{
+		{name: "acceptable", password: "Tr0ub4dor&3x", email: "jane.doe@example.com"},
+		{name: "too short", password: "Sh0rt!", want: []string{domain.PasswordRuleMinLength}},
+		{name: "length counts characters, not bytes", password: "Äöüäöüäö1!"},
+		{name: "too long", password: "Aa1!" + strings.Repeat("x", 69), want: []string{domain.PasswordRuleMaxLength}},
+		{name: "no uppercase", password: "tr0ub4dor&3x", want: []string{domain.PasswordRuleUppercase}},
+		{name: "no lowercase", password: "TR0UB4DOR&3X", want: []string{domain.PasswordRuleLowercase}},
+		{name: "no digit", password: "Troubador&xx", want: []string{domain.PasswordRuleDigit}},
+		{name: "space counts as symbol", password: "Tr0ub4dor 3x"},
+		{name: "no symbol", password: "Tr0ub4dor33x", want: []string{domain.PasswordRuleSymbol}},
+		{name: "contains the local part", password: "Jane.Doe#2026", email: "jane.doe@example.com", want: []string{domain.PasswordRuleContainsEmail}},
+		{name: "contains the address", password: "X1!jo@example.com", email: "JO@example.com", want: []string{domain.PasswordRuleContainsEmail}},
+		{name: "short local part alone is allowed", password: "Jo-Tr0ub4dor", email: "jo@example.com"},
+		{name: "breached", password: "password", want: []string{domain.PasswordRuleMinLength, domain.PasswordRuleUppercase, domain.PasswordRuleDigit, domain.PasswordRuleSymbol, domain.PasswordRuleBreached}},
+		{name: "breached ignoring case", password: "P@ssw0rd", want: []string{domain.PasswordRuleMinLength, domain.PasswordRuleBreached}},
+	}
+	for _, tt := range tests {
+		if got := violatedRules(t, policy.Validate(tt.password, tt.email)); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: violated rules = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPasswordPolicyDefaults(t *testing.T) {
+	policy, err := NewPasswordPolicy(&config.Config{PasswordMinLength: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := policy.Validate("password", ""); err != nil {
+		t.Errorf("breached password with the check disabled: %v", err)
+	}
+	// Without a configured maximum, bcrypt's limit applies
+	want := []string{domain.PasswordRuleMaxLength}
+	if got := violatedRules(t, policy.Validate(strings.Repeat("x", 73), "")); !slices.Equal(got, want) {
+		t.Errorf("violated rules = %v, want %v", got, want)
+	}
+}
+
+func TestNewPasswordPolicyRejectsBadConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+	}{
+		{name: "minimum above maximum", cfg: config.Config{PasswordMinLength: 20, PasswordMaxLength: 16}},
+		{name: "minimum above bcrypt's limit", cfg: config.Config{PasswordMinLength: 80, PasswordMaxLength: 100}},
+		{name: "unknown class", cfg: config.Config{PasswordRequiredClasses: []string{"emoji"}}},
+	}
+	for _, tt := range tests {
+		if _, err := NewPasswordPolicy(&tt.cfg); err == nil {
+			t.Errorf("%s: NewPasswordPolicy succeeded, want an error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"dummy-backend/lib/domain"
+	"dummy-backend/lib/repository"
+	"dummy-backend/pkg/config"
+	"dummy-backend/pkg/hasher"
+	"dummy-backend/pkg/mailer"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// forgotPasswordResponseTime is how long every forgot-password request
+// takes, long enough to cover looking up the user and handing the link to
+// the mailer
+const forgotPasswordResponseTime = 2 * time.Second
+
+type PasswordResetService interface {
+	// ForgotPassword emails a reset link if the address belongs to a user
+	// and has not asked for too many links lately. It reports success
+	// either way, after the same delay, so callers cannot probe for accounts.
+	ForgotPassword(req *domain.ForgotPasswordRequest) error
+	ResetPassword(req *domain.ResetPasswordRequest) error
+}
+
+type passwordResetService struct {
+	userRepo    repository.UserRepository
+	resetRepo   repository.PasswordResetRepository
+	authService AuthService
+	policy      PasswordPolicy
+	hasher      hasher.Hasher
+	mailer      mailer.Mailer
+	baseURL     string
+	tokenTTL    time.Duration
+	rateLimit   int
+	rateWindow  time.Duration
+}
+
+func NewPasswordResetService(userRepo repository.UserRepository, resetRepo repository.PasswordResetRepository, authService AuthService, policy PasswordPolicy, passwordHasher hasher.Hasher, m mailer.Mailer, cfg *config.Config) PasswordResetService {
+	return &passwordResetService{
+		userRepo:    userRepo,
+		resetRepo:   resetRepo,
+		authService: authService,
+		policy:      policy,
+		hasher:      passwordHasher,
+		mailer:      m,
+		baseURL:     cfg.AppBaseURL,
+		tokenTTL:    cfg.PasswordResetTTL,
+		rateLimit:   cfg.PasswordResetRateLimit,
+		rateWindow:  cfg.PasswordResetRateWindow,
+	}
+}
+
+func (s *passwordResetService) ForgotPassword(req *domain.ForgotPasswordRequest) error {
+	// The link is sent in the background and every request takes the same
+	// time, so the response does not tell registered addresses apart
+	go func() {
+		if err := s.sendResetLink(req.Email); err != nil {
+			log.Printf("Failed to send password reset link: %v", err)
+		}
+	}()
+	time.Sleep(forgotPasswordResponseTime)
+	return nil
+}
+
+func (s *passwordResetService) sendResetLink(email string) error {
+	user, err := s.userRepo.GetByEmail(email)
+	if err != nil {
+		return nil
+	}
+
+	// Keep anyone from flooding the inbox with reset links
+	sent, err := s.resetRepo.CountCreatedSince(user.ID, time.Now().Add(-s.rateWindow))
+	if err != nil {
+		return err
+	}
+	if sent >= int64(s.rateLimit) {
+		return nil
+	}
+
+	// Only the most recent link should work
+	if err := s.resetRepo.InvalidateForUser(user.ID); err != nil {
+		return err
+	}
+
+	token, hash, err := generateOpaqueToken()
+	if err != nil {
+		return err
+	}
+	err = s.resetRepo.Create(&domain.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(s.tokenTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := s.baseURL + "/reset-password?token=" + url.QueryEscape(token)
+	err = s.mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Someone asked to reset the password for this account.\n\n"+
+			"Open the link below within %s to choose a new password:\n\n%s\n\n"+
+			"If this wasn't you, you can ignore this email.\n", s.tokenTTL, link),
+	})
+	if err != nil {
+		return fmt.Errorf("user %d: %w", user.ID, err)
+	}
+	return nil
+}
+
+// ResetPassword sets a new password and ends all existing sessions, since
+// they may belong to whoever caused the reset.
+func (s *passwordResetService) ResetPassword(req *domain.ResetPasswordRequest) error {
+	stored, err := s.resetRepo.GetByHash(hashToken(req.Token))
+	if err != nil {
+		return ErrInvalidResetToken
+	}
+	if stored.UsedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	// Check the password before consuming the token, so the user can retry
+	user, err := s.userRepo.GetByID(stored.UserID)
+	if err != nil {
+		return ErrInvalidResetToken
+	}
+	if err := s.policy.Validate(req.NewPassword, user.Email); err != nil {
+		return err
+	}
+
+	consumed, err := s.resetRepo.MarkUsed(stored.ID)
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return ErrInvalidResetToken
+	}
+
+	hashedPassword, err := s.hasher.Hash(req.NewPassword)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdatePassword(user.ID, hashedPassword); err != nil {
+		return err
+	}
+
+	return s.authService.LogoutAll(user.ID)
+}
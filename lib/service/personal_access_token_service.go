@@ -0,0 +1,137 @@
+package service
+
+import (
+	"dummy-backend/lib/domain"
+	"dummy-backend/lib/repository"
+	"dummy-backend/pkg/config"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+// PersonalAccessTokenPrefix marks bearer tokens that are personal access
+// tokens rather than JWTs
+const PersonalAccessTokenPrefix = "pat_"
+
+const (
+	defaultPATLifetime = 30 * 24 * time.Hour
+	// lastUsedGranularity limits last_used_at writes to one per token per minute
+	lastUsedGranularity = time.Minute
+)
+
+var (
+	ErrInvalidPersonalAccessToken  = errors.New("invalid personal access token")
+	ErrPersonalAccessTokenNotFound = errors.New("personal access token not found")
+	ErrInvalidTokenExpiry          = errors.New("expires_at must be in the future and within the maximum token lifetime")
+)
+
+type PersonalAccessTokenService interface {
+	Create(userID uint, req *domain.CreatePersonalAccessTokenRequest) (*domain.CreatePersonalAccessTokenResponse, error)
+	List(userID uint) ([]domain.PersonalAccessToken, error)
+	Revoke(userID, id uint) error
+	// Authenticate resolves a plaintext token presented as a bearer token.
+	// The returned token has its User loaded.
+	Authenticate(token string) (*domain.PersonalAccessToken, error)
+}
+
+type personalAccessTokenService struct {
+	tokenRepo   repository.PersonalAccessTokenRepository
+	maxLifetime time.Duration
+}
+
+func NewPersonalAccessTokenService(tokenRepo repository.PersonalAccessTokenRepository, cfg *config.Config) PersonalAccessTokenService {
+	return &personalAccessTokenService{
+		tokenRepo:   tokenRepo,
+		maxLifetime: cfg.PATMaxLifetime,
+	}
+}
+
+func (s *personalAccessTokenService) Create(userID uint, req *domain.CreatePersonalAccessTokenRequest) (*domain.CreatePersonalAccessTokenResponse, error) {
+	now := time.Now()
+	expiresAt := now.Add(defaultPATLifetime)
+	if req.ExpiresAt != nil {
+		expiresAt = *req.ExpiresAt
+	}
+	if !expiresAt.After(now) || expiresAt.After(now.Add(s.maxLifetime)) {
+		return nil, ErrInvalidTokenExpiry
+	}
+
+	secret, _, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	plaintext := PersonalAccessTokenPrefix + secret
+
+	token := &domain.PersonalAccessToken{
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    plaintext[:len(PersonalAccessTokenPrefix)+6],
+		TokenHash: hashToken(plaintext),
+		Scopes:    dedupe(req.Scopes),
+		ExpiresAt: expiresAt,
+	}
+	if err := s.tokenRepo.Create(token); err != nil {
+		return nil, err
+	}
+
+	return &domain.CreatePersonalAccessTokenResponse{
+		Token:               plaintext,
+		PersonalAccessToken: *token,
+	}, nil
+}
+
+func (s *personalAccessTokenService) List(userID uint) ([]domain.PersonalAccessToken, error) {
+	return s.tokenRepo.ListByUser(userID)
+}
+
+func (s *personalAccessTokenService) Revoke(userID, id uint) error {
+	revoked, err := s.tokenRepo.Revoke(userID, id)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrPersonalAccessTokenNotFound
+	}
+	return nil
+}
+
+func (s *personalAccessTokenService) Authenticate(plaintext string) (*domain.PersonalAccessToken, error) {
+	if !strings.HasPrefix(plaintext, PersonalAccessTokenPrefix) {
+		return nil, ErrInvalidPersonalAccessToken
+	}
+
+	token, err := s.tokenRepo.GetByHash(hashToken(plaintext))
+	if err != nil {
+		return nil, ErrInvalidPersonalAccessToken
+	}
+	now := time.Now()
+	if token.RevokedAt != nil || now.After(token.ExpiresAt) {
+		return nil, ErrInvalidPersonalAccessToken
+	}
+	// Accounts that are disabled or waiting to be deleted can only be used
+	// interactively
+	if token.User == nil || token.User.DisabledAt != nil || token.User.DeletionScheduledAt != nil {
+		return nil, ErrInvalidPersonalAccessToken
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > lastUsedGranularity {
+		if err := s.tokenRepo.TouchLastUsed(token.ID, now); err != nil {
+			log.Printf("Failed to update last use of personal access token %d: %v", token.ID, err)
+		}
+	}
+
+	return token, nil
+}
+
+func dedupe(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
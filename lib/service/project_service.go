@@ -0,0 +1,143 @@
+package service
+
+import (
+	"dummy-backend/lib/domain"
+	"dummy-backend/lib/repository"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrProjectNotFound    = errors.New("project not found")
+	ErrInvalidProjectName = errors.New("project name must not be blank")
+	ErrProjectArchived    = errors.New("project is archived")
+	ErrUnknownTaskProject = errors.New("unknown project")
+)
+
+// ProjectService manages a user's projects. Like tasks, projects owned by
+// someone else are reported as not found.
+type ProjectService interface {
+	CreateProject(userID uint, req *domain.CreateProjectRequest) (*domain.Project, error)
+	ListProjects(userID uint, q *domain.ProjectListQuery) ([]domain.Project, error)
+	GetProject(userID, id uint) (*domain.Project, error)
+	UpdateProject(userID, id uint, req *domain.UpdateProjectRequest) (*domain.Project, error)
+	DeleteProject(userID, id uint) error
+}
+
+type projectService struct {
+	projectRepo repository.ProjectRepository
+}
+
+func NewProjectService(projectRepo repository.ProjectRepository) ProjectService {
+	return &projectService{projectRepo: projectRepo}
+}
+
+func (s *projectService) CreateProject(userID uint, req *domain.CreateProjectRequest) (*domain.Project, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, ErrInvalidProjectName
+	}
+
+	project := &domain.Project{UserID: userID, Name: name, Description: req.Description}
+	if err := s.projectRepo.Create(project); err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+func (s *projectService) ListProjects(userID uint, q *domain.ProjectListQuery) ([]domain.Project, error) {
+	projects, err := s.projectRepo.List(userID, q.IncludeArchived)
+	if err != nil {
+		return nil, err
+	}
+	if projects == nil {
+		projects = []domain.Project{}
+	}
+	if err := s.countTasks(userID, projects); err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+func (s *projectService) GetProject(userID, id uint) (*domain.Project, error) {
+	project, err := s.projectRepo.GetByID(userID, id)
+	if err != nil {
+		return nil, ErrProjectNotFound
+	}
+	projects := []domain.Project{*project}
+	if err := s.countTasks(userID, projects); err != nil {
+		return nil, err
+	}
+	return &projects[0], nil
+}
+
+func (s *projectService) UpdateProject(userID, id uint, req *domain.UpdateProjectRequest) (*domain.Project, error) {
+	project, err := s.projectRepo.GetByID(userID, id)
+	if err != nil {
+		return nil, ErrProjectNotFound
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, ErrInvalidProjectName
+		}
+		project.Name = name
+	}
+	if req.Description != nil {
+		project.Description = *req.Description
+	}
+	if req.Archived != nil {
+		project.Archived = *req.Archived
+	}
+
+	if err := s.projectRepo.Update(project); err != nil {
+		return nil, err
+	}
+	return s.GetProject(userID, id)
+}
+
+func (s *projectService) DeleteProject(userID, id uint) error {
+	if _, err := s.projectRepo.GetByID(userID, id); err != nil {
+		return ErrProjectNotFound
+	}
+	return s.projectRepo.Delete(userID, id)
+}
+
+// countTasks fills in the task counts of projects
+func (s *projectService) countTasks(userID uint, projects []domain.Project) error {
+	if len(projects) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(projects))
+	for i, project := range projects {
+		ids[i] = project.ID
+	}
+	counts, err := s.projectRepo.CountTasks(userID, ids)
+	if err != nil {
+		return err
+	}
+	for _, count := range counts {
+		for i := range projects {
+			if projects[i].ID == count.ProjectID {
+				projects[i].OpenTasks = count.Open
+				projects[i].DoneTasks = count.Done
+			}
+		}
+	}
+	return nil
+}
+
+// resolveProject checks that a task may be put in the project: it must be
+// one of the user's projects and not archived
+func resolveProject(projectRepo repository.ProjectRepository, userID, id uint) error {
+	project, err := projectRepo.GetByID(userID, id)
+	if err != nil {
+		return fmt.Errorf("%w %d", ErrUnknownTaskProject, id)
+	}
+	if project.Archived {
+		return ErrProjectArchived
+	}
+	return nil
+}
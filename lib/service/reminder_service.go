@@ -0,0 +1,87 @@
+package service
+
+import (
+	"dummy-backend/lib/domain"
+	"dummy-backend/lib/repository"
+	"dummy-backend/pkg/mailer"
+	"fmt"
+	"log"
+	"time"
+)
+
+const reminderBatchSize = 100
+
+// ReminderService notifies users about tasks whose remind_at has passed.
+// It is run periodically; every reminder is sent once, even with several
+// instances running.
+type ReminderService interface {
+	// SendDueReminders sends the reminders that are due and returns how
+	// many were sent
+	SendDueReminders() (int, error)
+}
+
+type reminderService struct {
+	taskRepo repository.TaskRepository
+	mailer   mailer.Mailer
+}
+
+func NewReminderService(taskRepo repository.TaskRepository, m mailer.Mailer) ReminderService {
+	return &reminderService{taskRepo: taskRepo, mailer: m}
+}
+
+func (s *reminderService) SendDueReminders() (int, error) {
+	sent := 0
+	for {
+		// Claimed tasks drop out of the query, so each batch is new
+		tasks, err := s.taskRepo.ListDueReminders(time.Now(), reminderBatchSize)
+		if err != nil {
+			return sent, err
+		}
+
+		for i := range tasks {
+			task := &tasks[i]
+			claimed, err := s.taskRepo.ClaimReminder(task.ID, *task.RemindAt)
+			if err != nil {
+				return sent, err
+			}
+			// Disabled users are skipped, but their reminders are used up
+			if !claimed || task.User == nil || task.User.DisabledAt != nil {
+				continue
+			}
+
+			if err := s.mailer.Send(reminderMessage(task)); err != nil {
+				// Stop and retry on the next run rather than lose reminders
+				// while the mail server is unavailable
+				if releaseErr := s.taskRepo.ReleaseReminder(task.ID); releaseErr != nil {
+					log.Printf("Failed to release reminder for task %d: %v", task.ID, releaseErr)
+				}
+				return sent, fmt.Errorf("send reminder for task %d: %w", task.ID, err)
+			}
+			sent++
+		}
+
+		if len(tasks) < reminderBatchSize {
+			return sent, nil
+		}
+	}
+}
+
+// reminderMessage shows the due time in the user's timezone
+func reminderMessage(task *domain.Task) *mailer.Message {
+	body := fmt.Sprintf("This is your reminder for the task %q.\n", task.Title)
+	if task.DueAt != nil {
+		loc := time.UTC
+		if task.User.Timezone != "" {
+			if l, err := time.LoadLocation(task.User.Timezone); err == nil {
+				loc = l
+			}
+		}
+		body += fmt.Sprintf("\nIt is due %s.\n", task.DueAt.In(loc).Format("Monday, 2 January 2006 15:04 MST"))
+	}
+
+	return &mailer.Message{
+		To:      task.User.Email,
+		Subject: "Reminder: " + task.Title,
+		Body:    body,
+	}
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"dummy-backend/lib/domain"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTaskPageSize = 20
+	defaultTaskSort     = "-created_at"
+)
+
+var ErrInvalidTaskQuery = errors.New("invalid task query")
+
+var taskPriorities = []string{domain.TaskPriorityLow, domain.TaskPriorityMedium, domain.TaskPriorityHigh, domain.TaskPriorityUrgent}
+
+// taskSortField describes a column clients may sort by. value extracts the
+// column from a task for the next cursor and parse turns it back into a
+// typed value for the keyset comparison.
+type taskSortField struct {
+	column string
+	value  func(task *domain.Task) string
+	parse  func(value string) (interface{}, error)
+}
+
+var taskSortFields = map[string]taskSortField{
+	"created_at": {
+		column: "created_at",
+		value:  func(task *domain.Task) string { return task.CreatedAt.Format(time.RFC3339Nano) },
+		parse:  parseCursorTime,
+	},
+	"title": {
+		column: "title",
+		value:  func(task *domain.Task) string { return task.Title },
+		parse:  func(value string) (interface{}, error) { return value, nil },
+	},
+}
+
+// taskCursor is the opaque keyset position returned as next_cursor. It
+// records the sort it was issued for so it cannot be replayed under another.
+type taskCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+func parseCursorTime(value string) (interface{}, error) {
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+func encodeTaskCursor(cursor taskCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeTaskCursor(encoded string) (*taskCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	var cursor taskCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// buildTaskFilter validates a list query and resolves its sort and cursor.
+// Due date windows are computed from now in loc. The returned function
+// builds the cursor that resumes after a given task.
+func buildTaskFilter(q *domain.TaskListQuery, now time.Time, loc *time.Location) (*domain.TaskFilter, func(*domain.Task) string, error) {
+	sort := q.Sort
+	if sort == "" {
+		sort = defaultTaskSort
+	}
+	desc := strings.HasPrefix(sort, "-")
+	field, ok := taskSortFields[strings.TrimPrefix(sort, "-")]
+	if !ok {
+		return nil, nil, errors.New("unsupported sort field")
+	}
+
+	filter := &domain.TaskFilter{
+		TitleContains: q.Search,
+		CreatedAfter:  q.CreatedAfter,
+		CreatedBefore: q.CreatedBefore,
+		ProjectID:     q.ProjectID,
+		ParentID:      q.ParentID,
+		SortColumn:    field.column,
+		SortDesc:      desc,
+		Limit:         q.Limit,
+		// Tasks of archived projects are only listed when asked for
+		ExcludeArchived: q.ProjectID == nil && q.ParentID == nil && !q.IncludeArchived,
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultTaskPageSize
+	}
+
+	var err error
+	if filter.Statuses, err = parseTaskEnumList(q.Status, domain.TaskStatuses); err != nil {
+		return nil, nil, fmt.Errorf("status: %w", err)
+	}
+	if filter.Priorities, err = parseTaskEnumList(q.Priority, taskPriorities); err != nil {
+		return nil, nil, fmt.Errorf("priority: %w", err)
+	}
+	// completed is the filter older clients use
+	if q.Completed != nil {
+		if len(filter.Statuses) > 0 {
+			return nil, nil, errors.New("use either status or completed")
+		}
+		if *q.Completed {
+			filter.Statuses = []string{domain.TaskStatusDone}
+		} else {
+			filter.ExcludeStatuses = []string{domain.TaskStatusDone}
+		}
+	}
+	if filter.LabelIDs, err = parseLabelIDs(q.Label); err != nil {
+		return nil, nil, fmt.Errorf("label: %w", err)
+	}
+	filter.LabelMatchAll = q.LabelMode == "all"
+	if q.Due != "" {
+		if err := applyDueWindow(filter, q.Due, now, loc); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if q.Cursor != "" {
+		cursor, err := decodeTaskCursor(q.Cursor)
+		if err != nil || cursor.Sort != sort {
+			return nil, nil, errors.New("invalid cursor")
+		}
+		value, err := field.parse(cursor.Value)
+		if err != nil {
+			return nil, nil, errors.New("invalid cursor")
+		}
+		filter.AfterValue = value
+		filter.AfterID = cursor.ID
+	}
+
+	nextCursor := func(task *domain.Task) string {
+		return encodeTaskCursor(taskCursor{Sort: sort, Value: field.value(task), ID: task.ID})
+	}
+
+	return filter, nextCursor, nil
+}
+
+// parseTaskEnumList splits a comma-separated query value and checks every
+// entry against allowed
+func parseTaskEnumList(value string, allowed []string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	values := strings.Split(value, ",")
+	for _, v := range values {
+		if !slices.Contains(allowed, v) {
+			return nil, fmt.Errorf("unknown value %q", v)
+		}
+	}
+	return values, nil
+}
+
+// parseLabelIDs splits a comma-separated list of label IDs, dropping
+// duplicates so that matching all of them counts each label once
+func parseLabelIDs(value string) ([]uint, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var ids []uint
+	for _, v := range strings.Split(value, ",") {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || id == 0 {
+			return nil, fmt.Errorf("invalid label ID %q", v)
+		}
+		if !slices.Contains(ids, uint(id)) {
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids, nil
+}
+
+// applyDueWindow narrows filter to the tasks due in the named window. Days
+// start at midnight in loc and weeks on Monday. Overdue tasks are unfinished
+// tasks whose due time has passed.
+func applyDueWindow(filter *domain.TaskFilter, due string, now time.Time, loc *time.Location) error {
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+
+	var start, end time.Time
+	switch due {
+	case "overdue":
+		filter.ExcludeStatuses = append(filter.ExcludeStatuses, domain.TaskStatusDone, domain.TaskStatusCancelled)
+		filter.DueBefore = &now
+		return nil
+	case "today":
+		start, end = today, today.AddDate(0, 0, 1)
+	case "week":
+		start = today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		end = start.AddDate(0, 0, 7)
+	default:
+		return errors.New("unsupported due filter")
+	}
+
+	filter.DueAfter = &start
+	filter.DueBefore = &end
+	return nil
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"dummy-backend/lib/domain"
+	"testing"
+	"time"
+)
+
+func TestTaskCursorRoundTrip(t *testing.T) {
+	cursor := taskCursor{Sort: "-created_at", Value: "2026-01-02T03:04:05.123456789Z", ID: 42}
+
+	decoded, err := decodeTaskCursor(encodeTaskCursor(cursor))
+	if err != nil {
+		t.Fatalf("decodeTaskCursor: %v", err)
+	}
+	if *decoded != cursor {
+		t.Errorf("decoded cursor = %+v, want %+v", *decoded, cursor)
+	}
+
+	for _, encoded := range []string{"not base64!", "bm90IGpzb24"} {
+		if _, err := decodeTaskCursor(encoded); err == nil {
+			t.Errorf("decodeTaskCursor(%q) succeeded, want an error", encoded)
+		}
+	}
+}
+
+func TestBuildTaskFilterCursor(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	task := &domain.Task{ID: 7, Title: "Write tests", CreatedAt: created}
+
+	tests := []struct {
+		sort      string
+		wantValue interface{}
+	}{
+		{sort: "", wantValue: created},
+		{sort: "created_at", wantValue: created},
+		{sort: "title", wantValue: "Write tests"},
+		{sort: "-title", wantValue: "Write tests"},
+	}
+	for _, tt := range tests {
+		_, nextCursor, err := buildTaskFilter(&domain.TaskListQuery{Sort: tt.sort}, time.Now(), time.UTC)
+		if err != nil {
+			t.Fatalf("sort %q: %v", tt.sort, err)
+		}
+
+		filter, _, err := buildTaskFilter(&domain.TaskListQuery{Sort: tt.sort, Cursor: nextCursor(task)}, time.Now(), time.UTC)
+		if err != nil {
+			t.Fatalf("sort %q with cursor: %v", tt.sort, err)
+		}
+		if filter.AfterID != task.ID {
+			t.Errorf("sort %q: AfterID = %d, want %d", tt.sort, filter.AfterID, task.ID)
+		}
+		if want, ok := tt.wantValue.(time.Time); ok {
+			if got, _ := filter.AfterValue.(time.Time); !got.Equal(want) {
+				t.Errorf("sort %q: AfterValue = %v, want %v", tt.sort, filter.AfterValue, want)
+			}
+		} else if filter.AfterValue != tt.wantValue {
+			t.Errorf("sort %q: AfterValue = %v, want %v", tt.sort, filter.AfterValue, tt.wantValue)
+		}
+	}
+}
+
+func TestBuildTaskFilterRejectsCursor(t *testing.T) {
+	task := &domain.Task{ID: 7, Title: "Write tests", CreatedAt: time.Now()}
+	_, nextCursor, err := buildTaskFilter(&domain.TaskListQuery{Sort: "title"}, time.Now(), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query domain.TaskListQuery
+	}{
+		{name: "issued for another sort", query: domain.TaskListQuery{Sort: "-title", Cursor: nextCursor(task)}},
+		{name: "not a cursor", query: domain.TaskListQuery{Sort: "title", Cursor: "garbage"}},
+		{name: "value of the wrong type", query: domain.TaskListQuery{Cursor: encodeTaskCursor(taskCursor{Sort: defaultTaskSort, Value: "yesterday", ID: 1})}},
+	}
+	for _, tt := range tests {
+		if _, _, err := buildTaskFilter(&tt.query, time.Now(), time.UTC); err == nil {
+			t.Errorf("%s: buildTaskFilter succeeded, want an error", tt.name)
+		}
+	}
+}
@@ -3,56 +3,222 @@ package service
 import (
 	"dummy-backend/lib/domain"
 	"dummy-backend/lib/repository"
+	"dummy-backend/pkg/config"
 	"errors"
+	"fmt"
+	"slices"
+	"time"
 )
 
+var (
+	ErrTaskNotFound          = errors.New("task not found")
+	ErrConflictingTaskStatus = errors.New("status and completed disagree")
+	ErrUnknownParentTask     = errors.New("unknown parent task")
+	ErrTaskCycle             = errors.New("a task cannot be moved below itself")
+	ErrTaskTooDeep           = errors.New("subtasks are nested too deeply")
+)
+
+// TaskService operates on behalf of a single user. Tasks owned by someone
+// else are reported as not found so that task IDs do not leak.
 type TaskService interface {
-	CreateTask(req *domain.CreateTaskRequest) (*domain.Task, error)
-	GetAllTasks() ([]domain.Task, error)
-	GetTaskByID(id uint) (*domain.Task, error)
-	UpdateTask(id uint, req *domain.UpdateTaskRequest) (*domain.Task, error)
-	DeleteTask(id uint) error
+	CreateTask(userID uint, req *domain.CreateTaskRequest) (*domain.Task, error)
+	ListTasks(userID uint, q *domain.TaskListQuery) (*domain.TaskListResponse, error)
+	GetTaskByID(userID, id uint) (*domain.Task, error)
+	UpdateTask(userID, id uint, req *domain.UpdateTaskRequest) (*domain.Task, error)
+	// DeleteTask deletes the task together with its subtasks
+	DeleteTask(userID, id uint) error
+	// ListSubtasks lists the task's direct subtasks like ListTasks
+	ListSubtasks(userID, id uint, q *domain.TaskListQuery) (*domain.TaskListResponse, error)
+	// GetSubtree returns the task with all of its subtasks nested below it
+	GetSubtree(userID, id uint) (*domain.Task, error)
 }
 
 type taskService struct {
-	taskRepo repository.TaskRepository
+	taskRepo    repository.TaskRepository
+	userRepo    repository.UserRepository
+	labelRepo   repository.LabelRepository
+	projectRepo repository.ProjectRepository
+	transitions map[string][]string
+	maxDepth    int
 }
 
-func NewTaskService(taskRepo repository.TaskRepository) TaskService {
-	return &taskService{taskRepo: taskRepo}
+// NewTaskService uses the status workflow from cfg, or the default one. It
+// fails if the configured workflow names an unknown status or the subtask
+// depth limit is not positive.
+func NewTaskService(taskRepo repository.TaskRepository, userRepo repository.UserRepository, labelRepo repository.LabelRepository, projectRepo repository.ProjectRepository, cfg *config.Config) (TaskService, error) {
+	transitions := cfg.TaskStatusTransitions
+	if transitions == nil {
+		transitions = domain.DefaultTaskTransitions
+	}
+	for from, targets := range transitions {
+		for _, status := range append([]string{from}, targets...) {
+			if !slices.Contains(domain.TaskStatuses, status) {
+				return nil, fmt.Errorf("unknown task status %q in workflow", status)
+			}
+		}
+	}
+
+	if cfg.TaskMaxDepth < 1 {
+		return nil, fmt.Errorf("task depth limit must be at least 1, got %d", cfg.TaskMaxDepth)
+	}
+
+	return &taskService{
+		taskRepo:    taskRepo,
+		userRepo:    userRepo,
+		labelRepo:   labelRepo,
+		projectRepo: projectRepo,
+		transitions: transitions,
+		maxDepth:    cfg.TaskMaxDepth,
+	}, nil
 }
 
-func (s *taskService) CreateTask(req *domain.CreateTaskRequest) (*domain.Task, error) {
+// CreateTask accepts any initial status; the workflow only governs changes
+func (s *taskService) CreateTask(userID uint, req *domain.CreateTaskRequest) (*domain.Task, error) {
+	status := req.Status
+	if req.Completed != nil {
+		var err error
+		if status, err = resolveCompleted(*req.Completed, status, domain.TaskStatusTodo); err != nil {
+			return nil, err
+		}
+	}
+	if status == "" {
+		status = domain.TaskStatusTodo
+	}
+	priority := req.Priority
+	if priority == "" {
+		priority = domain.TaskPriorityMedium
+	}
+	labels, err := resolveLabels(s.labelRepo, userID, req.LabelIDs)
+	if err != nil {
+		return nil, err
+	}
+	if req.ProjectID != nil {
+		if err := resolveProject(s.projectRepo, userID, *req.ProjectID); err != nil {
+			return nil, err
+		}
+	}
+	if req.ParentID != nil {
+		if err := s.checkParent(userID, 0, 1, *req.ParentID); err != nil {
+			return nil, err
+		}
+	}
+
 	task := &domain.Task{
+		UserID:      userID,
 		Title:       req.Title,
 		Description: req.Description,
-		Completed:   false,
+		Status:      status,
+		Priority:    priority,
+		ProjectID:   req.ProjectID,
+		ParentID:    req.ParentID,
+		DueAt:       req.DueAt,
+		RemindAt:    req.RemindAt,
+		Labels:      labels,
+	}
+	if status == domain.TaskStatusDone {
+		now := time.Now()
+		task.CompletedAt = &now
 	}
 
-	err := s.taskRepo.Create(task)
+	err = s.taskRepo.Create(task)
 	if err != nil {
 		return nil, err
 	}
 
+	task.Progress = &domain.TaskProgress{}
 	return task, nil
 }
 
-func (s *taskService) GetAllTasks() ([]domain.Task, error) {
-	return s.taskRepo.GetAll()
+func (s *taskService) ListTasks(userID uint, q *domain.TaskListQuery) (*domain.TaskListResponse, error) {
+	loc := time.UTC
+	if q.Due != "" {
+		loc = s.userLocation(userID)
+	}
+
+	filter, nextCursor, err := buildTaskFilter(q, time.Now(), loc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTaskQuery, err)
+	}
+
+	// Fetch one extra row to learn whether another page follows
+	limit := filter.Limit
+	filter.Limit++
+	tasks, total, err := s.taskRepo.List(userID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &domain.TaskListResponse{Data: tasks, Total: total}
+	if len(tasks) > limit {
+		response.Data = tasks[:limit]
+		response.NextCursor = nextCursor(&response.Data[limit-1])
+	}
+	if response.Data == nil {
+		response.Data = []domain.Task{}
+	}
+	if err := s.fillProgress(userID, response.Data); err != nil {
+		return nil, err
+	}
+
+	return response, nil
 }
 
-func (s *taskService) GetTaskByID(id uint) (*domain.Task, error) {
-	task, err := s.taskRepo.GetByID(id)
+func (s *taskService) GetTaskByID(userID, id uint) (*domain.Task, error) {
+	task, err := s.taskRepo.GetByID(userID, id)
 	if err != nil {
-		return nil, errors.New("task not found")
+		return nil, ErrTaskNotFound
 	}
-	return task, nil
+	return s.withProgress(userID, task)
 }
 
-func (s *taskService) UpdateTask(id uint, req *domain.UpdateTaskRequest) (*domain.Task, error) {
-	existingTask, err := s.taskRepo.GetByID(id)
+func (s *taskService) UpdateTask(userID, id uint, req *domain.UpdateTaskRequest) (*domain.Task, error) {
+	existingTask, err := s.taskRepo.GetByID(userID, id)
 	if err != nil {
-		return nil, errors.New("task not found")
+		return nil, ErrTaskNotFound
+	}
+
+	previousStatus := existingTask.Status
+	status := existingTask.Status
+	var requested string
+	if req.Status != nil {
+		status = *req.Status
+		requested = *req.Status
+	}
+	if req.Completed != nil {
+		if status, err = resolveCompleted(*req.Completed, requested, existingTask.Status); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.transition(existingTask, status); err != nil {
+		return nil, err
+	}
+	// Finishing a task finishes the work below it, so every unfinished
+	// subtask must be allowed to move to done
+	completing := status == domain.TaskStatusDone && previousStatus != domain.TaskStatusDone
+	if completing {
+		if err := s.checkSubtasksCompletable(userID, id); err != nil {
+			return nil, err
+		}
+	}
+	var labels []domain.Label
+	if req.LabelIDs != nil {
+		if labels, err = resolveLabels(s.labelRepo, userID, *req.LabelIDs); err != nil {
+			return nil, err
+		}
+	}
+	if req.ProjectID.Set && req.ProjectID.Value != nil && !sameID(req.ProjectID.Value, existingTask.ProjectID) {
+		if err := resolveProject(s.projectRepo, userID, *req.ProjectID.Value); err != nil {
+			return nil, err
+		}
+	}
+	if req.ParentID.Set && req.ParentID.Value != nil && !sameID(req.ParentID.Value, existingTask.ParentID) {
+		subtree, err := s.taskRepo.Subtree(userID, id)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkParent(userID, id, subtreeHeight(id, subtree), *req.ParentID.Value); err != nil {
+			return nil, err
+		}
 	}
 
 	// Update only provided fields
@@ -62,23 +228,263 @@ func (s *taskService) UpdateTask(id uint, req *domain.UpdateTaskRequest) (*domai
 	if req.Description != nil {
 		existingTask.Description = *req.Description
 	}
-	if req.Completed != nil {
-		existingTask.Completed = *req.Completed
+	if req.Priority != nil {
+		existingTask.Priority = *req.Priority
+	}
+	if req.ProjectID.Set {
+		existingTask.ProjectID = req.ProjectID.Value
+	}
+	if req.ParentID.Set {
+		existingTask.ParentID = req.ParentID.Value
+	}
+	if req.DueAt.Set {
+		existingTask.DueAt = req.DueAt.Value
+	}
+	if req.RemindAt.Set {
+		// A new reminder time gets a new reminder
+		existingTask.RemindAt = req.RemindAt.Value
+		existingTask.RemindedAt = nil
+	}
+
+	err = s.taskRepo.Transaction(func(repo repository.TaskRepository) error {
+		if err := repo.Update(userID, id, existingTask); err != nil {
+			return err
+		}
+		if req.LabelIDs != nil {
+			if err := repo.SetLabels(existingTask, labels); err != nil {
+				return err
+			}
+		}
+		if completing {
+			return repo.CompleteSubtasks(userID, id, s.completableStatuses(), *existingTask.CompletedAt)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.withProgress(userID, existingTask)
+}
+
+func (s *taskService) DeleteTask(userID, id uint) error {
+	_, err := s.taskRepo.GetByID(userID, id)
+	if err != nil {
+		return ErrTaskNotFound
+	}
+
+	return s.taskRepo.Delete(userID, id)
+}
+
+func (s *taskService) ListSubtasks(userID, id uint, q *domain.TaskListQuery) (*domain.TaskListResponse, error) {
+	if _, err := s.taskRepo.GetByID(userID, id); err != nil {
+		return nil, ErrTaskNotFound
+	}
+	q.ParentID = &id
+	return s.ListTasks(userID, q)
+}
+
+func (s *taskService) GetSubtree(userID, id uint) (*domain.Task, error) {
+	tasks, err := s.taskRepo.Subtree(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return nil, ErrTaskNotFound
+	}
+
+	children := make(map[uint][]domain.Task)
+	for _, task := range tasks[1:] {
+		children[*task.ParentID] = append(children[*task.ParentID], task)
+	}
+	root := buildSubtree(tasks[0], children)
+	return &root, nil
+}
+
+// buildSubtree nests the tasks below task and rolls up their progress
+func buildSubtree(task domain.Task, children map[uint][]domain.Task) domain.Task {
+	task.Progress = &domain.TaskProgress{}
+	for _, child := range children[task.ID] {
+		child = buildSubtree(child, children)
+		task.Progress.Done += child.Progress.Done
+		task.Progress.Total += child.Progress.Total
+		if child.Status == domain.TaskStatusDone {
+			task.Progress.Done++
+		}
+		if child.Status != domain.TaskStatusCancelled {
+			task.Progress.Total++
+		}
+		task.Subtasks = append(task.Subtasks, child)
+	}
+	return task
+}
+
+// subtreeHeight returns how many levels the tasks below root span,
+// counting root. tasks lists parents before their children.
+func subtreeHeight(root uint, tasks []domain.Task) int {
+	depths := map[uint]int{root: 1}
+	height := 1
+	for _, task := range tasks {
+		if task.ParentID == nil || task.ID == root {
+			continue
+		}
+		depth := depths[*task.ParentID] + 1
+		depths[task.ID] = depth
+		height = max(height, depth)
+	}
+	return height
+}
+
+// checkParent checks that a subtree of the given height, rooted at the task
+// with taskID (0 for a new task), can be put below parentID without forming
+// a cycle or exceeding the depth limit
+func (s *taskService) checkParent(userID, taskID uint, height int, parentID uint) error {
+	ancestors, err := s.taskRepo.AncestorIDs(userID, parentID)
+	if err != nil {
+		return err
+	}
+	if len(ancestors) == 0 {
+		return fmt.Errorf("%w %d", ErrUnknownParentTask, parentID)
+	}
+	if slices.Contains(ancestors, taskID) {
+		return ErrTaskCycle
 	}
+	if len(ancestors)+height > s.maxDepth {
+		return fmt.Errorf("%w: at most %d levels are allowed", ErrTaskTooDeep, s.maxDepth)
+	}
+	return nil
+}
 
-	err = s.taskRepo.Update(id, existingTask)
+// fillProgress sets the subtask progress of tasks
+func (s *taskService) fillProgress(userID uint, tasks []domain.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(tasks))
+	for i := range tasks {
+		ids[i] = tasks[i].ID
+		tasks[i].Progress = &domain.TaskProgress{}
+	}
+	counts, err := s.taskRepo.SubtaskProgress(userID, ids)
 	if err != nil {
+		return err
+	}
+	for _, count := range counts {
+		for i := range tasks {
+			if tasks[i].ID == count.TaskID {
+				tasks[i].Progress.Done = count.Done
+				tasks[i].Progress.Total = count.Total
+			}
+		}
+	}
+	return nil
+}
+
+// withProgress returns task with its subtask progress set
+func (s *taskService) withProgress(userID uint, task *domain.Task) (*domain.Task, error) {
+	tasks := []domain.Task{*task}
+	if err := s.fillProgress(userID, tasks); err != nil {
 		return nil, err
 	}
+	return &tasks[0], nil
+}
+
+// transition moves task to status if the workflow allows it. completed_at
+// records when the task was last finished and is cleared when it is reopened.
+func (s *taskService) transition(task *domain.Task, status string) error {
+	if status == task.Status {
+		return nil
+	}
+	allowed := s.transitions[task.Status]
+	if !slices.Contains(allowed, status) {
+		return &StatusTransitionError{From: task.Status, To: status, Allowed: append([]string{}, allowed...)}
+	}
+
+	switch {
+	case status == domain.TaskStatusDone:
+		now := time.Now()
+		task.CompletedAt = &now
+	case task.Status == domain.TaskStatusDone:
+		task.CompletedAt = nil
+	}
+	task.Status = status
+	return nil
+}
+
+// completableStatuses lists the unfinished statuses the workflow lets a
+// task move to done from
+func (s *taskService) completableStatuses() []string {
+	var statuses []string
+	for _, status := range domain.TaskStatuses {
+		if status != domain.TaskStatusCancelled && slices.Contains(s.transitions[status], domain.TaskStatusDone) {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+// checkSubtasksCompletable returns a BlockingSubtasksError naming the
+// unfinished tasks below the task that the workflow does not allow to move
+// to done
+func (s *taskService) checkSubtasksCompletable(userID, id uint) error {
+	subtree, err := s.taskRepo.Subtree(userID, id)
+	if err != nil {
+		return err
+	}
+
+	completable := s.completableStatuses()
+	var blocking []uint
+	for _, task := range subtree {
+		if task.ID == id || task.Status == domain.TaskStatusDone || task.Status == domain.TaskStatusCancelled {
+			continue
+		}
+		if !slices.Contains(completable, task.Status) {
+			blocking = append(blocking, task.ID)
+		}
+	}
+	if len(blocking) > 0 {
+		return &BlockingSubtasksError{TaskIDs: blocking}
+	}
+	return nil
+}
 
-	return existingTask, nil
+// resolveCompleted maps the completed flag of older clients to a status.
+// A requested status wins as long as the flag agrees with it: true only
+// goes with done, false with anything else. Without one, clearing the flag
+// reopens a done task and leaves other statuses alone.
+func resolveCompleted(completed bool, requested, current string) (string, error) {
+	if requested != "" {
+		if completed != (requested == domain.TaskStatusDone) {
+			return "", ErrConflictingTaskStatus
+		}
+		return requested, nil
+	}
+	if completed {
+		return domain.TaskStatusDone, nil
+	}
+	if current == domain.TaskStatusDone {
+		return domain.TaskStatusTodo, nil
+	}
+	return current, nil
 }
 
-func (s *taskService) DeleteTask(id uint) error {
-	_, err := s.taskRepo.GetByID(id)
+// userLocation returns the user's timezone, or UTC if none is set
+func (s *taskService) userLocation(userID uint) *time.Location {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil || user.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(user.Timezone)
 	if err != nil {
-		return errors.New("task not found")
+		return time.UTC
 	}
+	return loc
+}
 
-	return s.taskRepo.Delete(id)
+// sameID reports whether two optional IDs are equal
+func sameID(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
 }
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"dummy-backend/lib/domain"
+	"dummy-backend/lib/repository"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// treeTaskRepo answers tree queries from a fixed parent map and subtree
+type treeTaskRepo struct {
+	repository.TaskRepository
+	// parents maps each task to its parent; top-level tasks map to 0
+	parents map[uint]uint
+	subtree []domain.Task
+}
+
+func (r *treeTaskRepo) AncestorIDs(userID, id uint) ([]uint, error) {
+	var ids []uint
+	for ; id != 0; id = r.parents[id] {
+		if _, ok := r.parents[id]; !ok {
+			break
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (r *treeTaskRepo) Subtree(userID, id uint) ([]domain.Task, error) {
+	return r.subtree, nil
+}
+
+func parent(id uint) *uint { return &id }
+
+func TestSubtreeHeight(t *testing.T) {
+	tasks := []domain.Task{
+		{ID: 1, ParentID: parent(9)},
+		{ID: 2, ParentID: parent(1)},
+		{ID: 3, ParentID: parent(1)},
+		{ID: 4, ParentID: parent(3)},
+		{ID: 5, ParentID: parent(4)},
+	}
+
+	if got := subtreeHeight(1, tasks); got != 4 {
+		t.Errorf("subtreeHeight of the whole tree = %d, want 4", got)
+	}
+	if got := subtreeHeight(1, tasks[:3]); got != 2 {
+		t.Errorf("subtreeHeight with one level of subtasks = %d, want 2", got)
+	}
+	if got := subtreeHeight(1, tasks[:1]); got != 1 {
+		t.Errorf("subtreeHeight of a leaf = %d, want 1", got)
+	}
+}
+
+func TestCheckParent(t *testing.T) {
+	// 1 > 2 > 3 > 4, and 5 on its own
+	repo := &treeTaskRepo{parents: map[uint]uint{1: 0, 2: 1, 3: 2, 4: 3, 5: 0}}
+	s := &taskService{taskRepo: repo, maxDepth: 4}
+
+	tests := []struct {
+		name     string
+		taskID   uint
+		height   int
+		parentID uint
+		wantErr  error
+	}{
+		{name: "new task below a top-level task", height: 1, parentID: 1},
+		{name: "new task at the depth limit", height: 1, parentID: 3},
+		{name: "new task beyond the depth limit", height: 1, parentID: 4, wantErr: ErrTaskTooDeep},
+		{name: "subtree that fits", taskID: 5, height: 2, parentID: 2},
+		{name: "subtree that is too tall", taskID: 5, height: 2, parentID: 3, wantErr: ErrTaskTooDeep},
+		{name: "below itself", taskID: 2, height: 3, parentID: 2, wantErr: ErrTaskCycle},
+		{name: "below its own subtask", taskID: 2, height: 3, parentID: 4, wantErr: ErrTaskCycle},
+		{name: "unknown parent", height: 1, parentID: 99, wantErr: ErrUnknownParentTask},
+	}
+	for _, tt := range tests {
+		err := s.checkParent(1, tt.taskID, tt.height, tt.parentID)
+		if tt.wantErr == nil && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBuildSubtreeRollsUpProgress(t *testing.T) {
+	root := domain.Task{ID: 1, Status: domain.TaskStatusInProgress}
+	children := map[uint][]domain.Task{
+		1: {
+			{ID: 2, Status: domain.TaskStatusDone},
+			{ID: 3, Status: domain.TaskStatusTodo},
+			{ID: 4, Status: domain.TaskStatusCancelled},
+		},
+		3: {
+			{ID: 5, Status: domain.TaskStatusDone},
+			{ID: 6, Status: domain.TaskStatusBlocked},
+		},
+	}
+
+	tree := buildSubtree(root, children)
+	if tree.Progress.Done != 2 || tree.Progress.Total != 4 {
+		t.Errorf("root progress = %+v, want 2 of 4, not counting the cancelled subtask", *tree.Progress)
+	}
+	if len(tree.Subtasks) != 3 {
+		t.Fatalf("root has %d subtasks, want 3", len(tree.Subtasks))
+	}
+	if progress := tree.Subtasks[1].Progress; progress.Done != 1 || progress.Total != 2 {
+		t.Errorf("progress of task 3 = %+v, want 1 of 2", *progress)
+	}
+	if progress := tree.Subtasks[0].Progress; progress.Done != 0 || progress.Total != 0 {
+		t.Errorf("progress of a leaf = %+v, want none", *progress)
+	}
+}
+
+func TestCheckSubtasksCompletable(t *testing.T) {
+	repo := &treeTaskRepo{subtree: []domain.Task{
+		{ID: 1, Status: domain.TaskStatusBlocked},
+		{ID: 2, Status: domain.TaskStatusTodo},
+		{ID: 3, Status: domain.TaskStatusBlocked},
+		{ID: 4, Status: domain.TaskStatusDone},
+		{ID: 5, Status: domain.TaskStatusCancelled},
+		{ID: 6, Status: domain.TaskStatusBlocked},
+	}}
+	s := &taskService{taskRepo: repo, transitions: domain.DefaultTaskTransitions}
+
+	if got, want := s.completableStatuses(), []string{domain.TaskStatusTodo, domain.TaskStatusInProgress}; !slices.Equal(got, want) {
+		t.Errorf("completableStatuses = %v, want %v", got, want)
+	}
+
+	// The task itself does not count, only blocked subtasks do
+	var blocking *BlockingSubtasksError
+	if err := s.checkSubtasksCompletable(1, 1); !errors.As(err, &blocking) || !slices.Equal(blocking.TaskIDs, []uint{3, 6}) {
+		t.Errorf("error = %v, want subtasks 3 and 6 blocking", err)
+	}
+
+	repo.subtree = repo.subtree[:2]
+	if err := s.checkSubtasksCompletable(1, 1); err != nil {
+		t.Errorf("with only completable subtasks: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"dummy-backend/lib/domain"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTransition(t *testing.T) {
+	s := &taskService{transitions: domain.DefaultTaskTransitions}
+	earlier := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name          string
+		from          string
+		to            string
+		completedAt   *time.Time
+		wantErr       bool
+		wantCompleted bool
+	}{
+		{name: "start", from: domain.TaskStatusTodo, to: domain.TaskStatusInProgress},
+		{name: "finish", from: domain.TaskStatusInProgress, to: domain.TaskStatusDone, wantCompleted: true},
+		{name: "reopen", from: domain.TaskStatusDone, to: domain.TaskStatusTodo, completedAt: &earlier},
+		{name: "unchanged done keeps its completion time", from: domain.TaskStatusDone, to: domain.TaskStatusDone, completedAt: &earlier, wantCompleted: true},
+		{name: "not allowed by the workflow", from: domain.TaskStatusBlocked, to: domain.TaskStatusDone, wantErr: true},
+		{name: "cancelled tasks only go back to todo", from: domain.TaskStatusCancelled, to: domain.TaskStatusInProgress, wantErr: true},
+	}
+	for _, tt := range tests {
+		task := &domain.Task{Status: tt.from, CompletedAt: tt.completedAt}
+		err := s.transition(task, tt.to)
+
+		if tt.wantErr {
+			var transitionErr *StatusTransitionError
+			if !errors.As(err, &transitionErr) {
+				t.Errorf("%s: error = %v, want *StatusTransitionError", tt.name, err)
+				continue
+			}
+			if transitionErr.From != tt.from || transitionErr.To != tt.to || !slices.Equal(transitionErr.Allowed, domain.DefaultTaskTransitions[tt.from]) {
+				t.Errorf("%s: error = %+v, want the attempted move and the allowed targets", tt.name, transitionErr)
+			}
+			if task.Status != tt.from {
+				t.Errorf("%s: status changed to %q on a refused move", tt.name, task.Status)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if task.Status != tt.to {
+			t.Errorf("%s: status = %q, want %q", tt.name, task.Status, tt.to)
+		}
+		if (task.CompletedAt != nil) != tt.wantCompleted {
+			t.Errorf("%s: CompletedAt = %v, want set = %v", tt.name, task.CompletedAt, tt.wantCompleted)
+		}
+		if tt.from == tt.to && task.CompletedAt != tt.completedAt {
+			t.Errorf("%s: CompletedAt changed without a status change", tt.name)
+		}
+	}
+}
+
+func TestTransitionFollowsConfiguredWorkflow(t *testing.T) {
+	s := &taskService{transitions: map[string][]string{
+		domain.TaskStatusTodo: {domain.TaskStatusDone},
+	}}
+
+	if err := s.transition(&domain.Task{Status: domain.TaskStatusTodo}, domain.TaskStatusDone); err != nil {
+		t.Errorf("todo to done: %v", err)
+	}
+	// Statuses without an entry are final
+	if err := s.transition(&domain.Task{Status: domain.TaskStatusDone}, domain.TaskStatusTodo); err == nil {
+		t.Error("done to todo succeeded, want an error")
+	}
+}
+
+func TestResolveCompleted(t *testing.T) {
+	tests := []struct {
+		name      string
+		completed bool
+		requested string
+		current   string
+		want      string
+		wantErr   bool
+	}{
+		{name: "complete", completed: true, current: domain.TaskStatusInProgress, want: domain.TaskStatusDone},
+		{name: "reopen a done task", completed: false, current: domain.TaskStatusDone, want: domain.TaskStatusTodo},
+		{name: "clearing the flag leaves other statuses alone", completed: false, current: domain.TaskStatusBlocked, want: domain.TaskStatusBlocked},
+		{name: "flag agrees with done", completed: true, requested: domain.TaskStatusDone, current: domain.TaskStatusTodo, want: domain.TaskStatusDone},
+		{name: "false goes with any other status", completed: false, requested: domain.TaskStatusCancelled, current: domain.TaskStatusDone, want: domain.TaskStatusCancelled},
+		{name: "true with another status", completed: true, requested: domain.TaskStatusInProgress, current: domain.TaskStatusTodo, wantErr: true},
+		{name: "false with done", completed: false, requested: domain.TaskStatusDone, current: domain.TaskStatusTodo, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := resolveCompleted(tt.completed, tt.requested, tt.current)
+		if tt.wantErr {
+			if !errors.Is(err, ErrConflictingTaskStatus) {
+				t.Errorf("%s: error = %v, want ErrConflictingTaskStatus", tt.name, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: resolveCompleted = (%q, %v), want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
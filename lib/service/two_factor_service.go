@@ -0,0 +1,206 @@
+package service
+
+import (
+	"crypto/rand"
+	"dummy-backend/lib/domain"
+	"dummy-backend/lib/repository"
+	"dummy-backend/pkg/config"
+	"dummy-backend/pkg/totp"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	recoveryCodeCount = 10
+	// totpSkew accepts codes from one period either side to allow for clock drift
+	totpSkew = 1
+)
+
+var (
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotSetUp       = errors.New("two-factor setup has not been started")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+)
+
+type TwoFactorService interface {
+	// Setup starts enrollment by generating a new secret. It only takes
+	// effect once confirmed with a code from the authenticator app.
+	Setup(userID uint) (*domain.TwoFactorSetupResponse, error)
+	Confirm(userID uint, code string) (*domain.RecoveryCodesResponse, error)
+	Disable(userID uint, code string) error
+	RegenerateRecoveryCodes(userID uint, code string) (*domain.RecoveryCodesResponse, error)
+	// VerifyCode accepts either a current TOTP code or an unused recovery code
+	VerifyCode(user *domain.User, code string) error
+}
+
+type twoFactorService struct {
+	userRepo         repository.UserRepository
+	recoveryCodeRepo repository.RecoveryCodeRepository
+	issuer           string
+}
+
+func NewTwoFactorService(userRepo repository.UserRepository, recoveryCodeRepo repository.RecoveryCodeRepository, cfg *config.Config) TwoFactorService {
+	return &twoFactorService{
+		userRepo:         userRepo,
+		recoveryCodeRepo: recoveryCodeRepo,
+		issuer:           cfg.TOTPIssuer,
+	}
+}
+
+func (s *twoFactorService) Setup(userID uint) (*domain.TwoFactorSetupResponse, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	if user.TOTPEnabledAt != nil {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.SetTOTPSecret(user.ID, secret); err != nil {
+		return nil, err
+	}
+
+	return &domain.TwoFactorSetupResponse{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(s.issuer, user.Email, secret),
+	}, nil
+}
+
+func (s *twoFactorService) Confirm(userID uint, code string) (*domain.RecoveryCodesResponse, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	if user.TOTPEnabledAt != nil {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotSetUp
+	}
+
+	if err := s.verifyTOTP(user, code); err != nil {
+		return nil, err
+	}
+
+	codes, err := s.replaceRecoveryCodes(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.EnableTOTP(user.ID, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func (s *twoFactorService) Disable(userID uint, code string) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	if user.TOTPEnabledAt == nil {
+		return ErrTwoFactorNotEnabled
+	}
+
+	if err := s.VerifyCode(user, code); err != nil {
+		return err
+	}
+
+	if err := s.recoveryCodeRepo.DeleteForUser(user.ID); err != nil {
+		return err
+	}
+	return s.userRepo.DisableTOTP(user.ID)
+}
+
+func (s *twoFactorService) RegenerateRecoveryCodes(userID uint, code string) (*domain.RecoveryCodesResponse, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	if user.TOTPEnabledAt == nil {
+		return nil, ErrTwoFactorNotEnabled
+	}
+
+	if err := s.verifyTOTP(user, code); err != nil {
+		return nil, err
+	}
+
+	return s.replaceRecoveryCodes(user.ID)
+}
+
+func (s *twoFactorService) VerifyCode(user *domain.User, code string) error {
+	if user.TOTPSecret == "" {
+		return ErrTwoFactorNotEnabled
+	}
+
+	code = normalizeCode(code)
+	if len(code) == totp.Digits {
+		return s.verifyTOTP(user, code)
+	}
+
+	used, err := s.recoveryCodeRepo.Consume(user.ID, hashToken(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+func (s *twoFactorService) verifyTOTP(user *domain.User, code string) error {
+	step, ok := totp.Validate(user.TOTPSecret, normalizeCode(code), time.Now(), totpSkew)
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+
+	// Each code may only be used once
+	fresh, err := s.userRepo.AdvanceTOTPStep(user.ID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+func (s *twoFactorService) replaceRecoveryCodes(userID uint) (*domain.RecoveryCodesResponse, error) {
+	plain := make([]string, recoveryCodeCount)
+	stored := make([]domain.RecoveryCode, recoveryCodeCount)
+	for i := range plain {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		plain[i] = code
+		stored[i] = domain.RecoveryCode{UserID: userID, CodeHash: hashToken(normalizeCode(code))}
+	}
+
+	if err := s.recoveryCodeRepo.Replace(userID, stored); err != nil {
+		return nil, err
+	}
+	return &domain.RecoveryCodesResponse{RecoveryCodes: plain}, nil
+}
+
+// generateRecoveryCode returns an 80-bit code formatted as XXXX-XXXX-XXXX-XXXX
+func generateRecoveryCode() (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	raw := base32.StdEncoding.EncodeToString(buf)
+	return raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16], nil
+}
+
+// normalizeCode lets users type codes with spaces, dashes or in lower case
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
@@ -0,0 +1,354 @@
+package service
+
+import (
+	"archive/zip"
+	"dummy-backend/lib/domain"
+	"dummy-backend/lib/repository"
+	"dummy-backend/pkg/config"
+	"dummy-backend/pkg/hasher"
+	"dummy-backend/pkg/mailer"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+)
+
+var (
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+	ErrEmailTaken        = errors.New("email is already in use")
+	ErrEmailUnchanged    = errors.New("email is the same as the current one")
+	ErrDeletionScheduled = errors.New("account deletion is already scheduled")
+	ErrNoDeletionPending = errors.New("account deletion is not scheduled")
+	ErrPasswordNotSet    = errors.New("account has no password, set one through a password reset link first")
+)
+
+const exportBatchSize = 500
+
+// UserService lets users manage their own account
+type UserService interface {
+	GetProfile(userID uint) (*domain.User, error)
+	UpdateProfile(userID uint, req *domain.UpdateProfileRequest) (*domain.User, error)
+	// ChangePassword and ChangeEmail confirm the current password, counting
+	// wrong guesses like failed logins from clientIP
+	ChangePassword(userID uint, req *domain.ChangePasswordRequest, clientIP string) error
+	ChangeEmail(userID uint, req *domain.ChangeEmailRequest, clientIP string) (*domain.User, error)
+	// DeleteAccount schedules the account for deletion after the grace
+	// period and logs out every session. Logging in again and calling
+	// RestoreAccount cancels it.
+	DeleteAccount(userID uint, req *domain.DeleteAccountRequest, clientIP string) (*domain.User, error)
+	RestoreAccount(userID uint, clientIP string) (*domain.User, error)
+	// PurgeDeletedAccounts deletes accounts whose grace period has ended and
+	// returns how many were deleted
+	PurgeDeletedAccounts() (int, error)
+	// ExportData writes a ZIP archive with the user's profile and tasks as JSON
+	ExportData(userID uint, w io.Writer) error
+}
+
+type userService struct {
+	userRepo      repository.UserRepository
+	taskRepo      repository.TaskRepository
+	auditRepo     repository.AuditRepository
+	authService   AuthService
+	verification  EmailVerificationService
+	loginGuard    LoginGuard
+	policy        PasswordPolicy
+	hasher        hasher.Hasher
+	mailer        mailer.Mailer
+	deletionGrace time.Duration
+}
+
+func NewUserService(userRepo repository.UserRepository, taskRepo repository.TaskRepository, auditRepo repository.AuditRepository, authService AuthService, verification EmailVerificationService, loginGuard LoginGuard, policy PasswordPolicy, passwordHasher hasher.Hasher, m mailer.Mailer, cfg *config.Config) UserService {
+	return &userService{
+		userRepo:      userRepo,
+		taskRepo:      taskRepo,
+		auditRepo:     auditRepo,
+		authService:   authService,
+		verification:  verification,
+		loginGuard:    loginGuard,
+		policy:        policy,
+		hasher:        passwordHasher,
+		mailer:        m,
+		deletionGrace: cfg.AccountDeletionGracePeriod,
+	}
+}
+
+func (s *userService) GetProfile(userID uint) (*domain.User, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (s *userService) UpdateProfile(userID uint, req *domain.UpdateProfileRequest) (*domain.User, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if req.DisplayName != nil {
+		user.DisplayName = strings.TrimSpace(*req.DisplayName)
+	}
+	if req.Timezone != nil {
+		user.Timezone = *req.Timezone
+	}
+	if req.Locale != nil {
+		user.Locale = *req.Locale
+	}
+
+	if err := s.userRepo.UpdateProfile(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ChangePassword sets a new password and ends every session, including the
+// current one, so a stolen token cannot outlive the change.
+func (s *userService) ChangePassword(userID uint, req *domain.ChangePasswordRequest, clientIP string) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if err := s.checkPassword(user, req.CurrentPassword, clientIP); err != nil {
+		return err
+	}
+	if err := s.policy.Validate(req.NewPassword, user.Email); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.hasher.Hash(req.NewPassword)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdatePassword(user.ID, hashedPassword); err != nil {
+		return err
+	}
+
+	return s.authService.LogoutAll(user.ID)
+}
+
+// ChangeEmail moves the account to a new address, which has to be verified
+// again. The old address is told about the change.
+func (s *userService) ChangeEmail(userID uint, req *domain.ChangeEmailRequest, clientIP string) (*domain.User, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if strings.EqualFold(user.Email, req.Email) {
+		return nil, ErrEmailUnchanged
+	}
+	if err := s.checkPassword(user, req.Password, clientIP); err != nil {
+		return nil, err
+	}
+	if existing, _ := s.userRepo.GetByEmail(req.Email); existing != nil {
+		return nil, ErrEmailTaken
+	}
+
+	oldEmail := user.Email
+	if err := s.userRepo.UpdateEmail(user.ID, req.Email); err != nil {
+		return nil, err
+	}
+	user.Email = domain.NormalizeEmail(req.Email)
+	user.VerifiedAt = nil
+	user.VerificationSentAt = nil
+
+	// The user can ask for another email, so don't fail the change
+	if err := s.verification.SendVerification(user); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+	}
+	err = s.mailer.Send(&mailer.Message{
+		To:      oldEmail,
+		Subject: "Your email address was changed",
+		Body: fmt.Sprintf("The email address of your account was changed to %s.\n\n"+
+			"If this wasn't you, reset your password and contact support.\n", user.Email),
+	})
+	if err != nil {
+		log.Printf("Failed to send email change notice to user %d: %v", user.ID, err)
+	}
+
+	return user, nil
+}
+
+func (s *userService) DeleteAccount(userID uint, req *domain.DeleteAccountRequest, clientIP string) (*domain.User, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if user.DeletionScheduledAt != nil {
+		return nil, ErrDeletionScheduled
+	}
+	if err := s.checkPassword(user, req.Password, clientIP); err != nil {
+		return nil, err
+	}
+
+	deleteAt := time.Now().Add(s.deletionGrace)
+	if err := s.userRepo.ScheduleDeletion(user.ID, &deleteAt); err != nil {
+		return nil, err
+	}
+	user.DeletionScheduledAt = &deleteAt
+	if err := s.authService.LogoutAll(user.ID); err != nil {
+		return nil, err
+	}
+	if err := s.audit(user.ID, domain.AuditDeletionScheduled, clientIP, deleteAt.Format(time.RFC3339)); err != nil {
+		return nil, err
+	}
+
+	err = s.mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: "Your account will be deleted",
+		Body: fmt.Sprintf("Your account and all of its tasks will be deleted on %s.\n\n"+
+			"To keep your account, log in before then and restore it.\n", deleteAt.UTC().Format(time.RFC1123)),
+	})
+	if err != nil {
+		log.Printf("Failed to send deletion notice to user %d: %v", user.ID, err)
+	}
+
+	return user, nil
+}
+
+func (s *userService) RestoreAccount(userID uint, clientIP string) (*domain.User, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if user.DeletionScheduledAt == nil {
+		return nil, ErrNoDeletionPending
+	}
+
+	if err := s.userRepo.ScheduleDeletion(user.ID, nil); err != nil {
+		return nil, err
+	}
+	user.DeletionScheduledAt = nil
+
+	return user, s.audit(user.ID, domain.AuditDeletionCancelled, clientIP, "")
+}
+
+func (s *userService) PurgeDeletedAccounts() (int, error) {
+	users, err := s.userRepo.ListDueForDeletion(time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	for i, user := range users {
+		if err := s.purge(&user); err != nil {
+			return i, fmt.Errorf("delete user %d: %w", user.ID, err)
+		}
+	}
+	return len(users), nil
+}
+
+// purge deletes the user for good. Audit events are kept for security
+// reviews but no longer point at the person.
+func (s *userService) purge(user *domain.User) error {
+	if err := s.auditRepo.Anonymize(user.ID, accountKey(user.Email)); err != nil {
+		return err
+	}
+	if err := s.loginGuard.RecordSuccess(user.Email); err != nil {
+		return err
+	}
+	if err := s.userRepo.Delete(user.ID); err != nil {
+		return err
+	}
+
+	return s.auditRepo.Create(&domain.AuditEvent{
+		Action:  domain.AuditUserDeleted,
+		Subject: fmt.Sprintf("user:%d", user.ID),
+	})
+}
+
+func (s *userService) ExportData(userID uint, w io.Writer) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	archive := zip.NewWriter(w)
+
+	profile, err := archive.Create("profile.json")
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(profile)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(user); err != nil {
+		return err
+	}
+
+	tasks, err := archive.Create("tasks.json")
+	if err != nil {
+		return err
+	}
+	if err := s.writeTasks(user.ID, tasks); err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+// writeTasks writes the user's tasks as a JSON array without holding them
+// all in memory
+func (s *userService) writeTasks(userID uint, w io.Writer) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	first := true
+	err := s.taskRepo.ForEachBatch(userID, exportBatchSize, func(tasks []domain.Task) error {
+		for _, task := range tasks {
+			raw, err := json.MarshalIndent(task, "  ", "  ")
+			if err != nil {
+				return err
+			}
+			separator := ",\n  "
+			if first {
+				separator, first = "\n  ", false
+			}
+			if _, err := io.WriteString(w, separator); err != nil {
+				return err
+			}
+			if _, err := w.Write(raw); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n]\n")
+	return err
+}
+
+func (s *userService) audit(userID uint, action, clientIP, details string) error {
+	return s.auditRepo.Create(&domain.AuditEvent{
+		UserID:  &userID,
+		Action:  action,
+		Subject: fmt.Sprintf("user:%d", userID),
+		IP:      clientIP,
+		Details: details,
+	})
+}
+
+func (s *userService) checkPassword(user *domain.User, password, clientIP string) error {
+	if user.Password == "" {
+		return ErrPasswordNotSet
+	}
+	if err := s.loginGuard.Check(user.Email, clientIP); err != nil {
+		return err
+	}
+	ok, _, err := s.hasher.Verify(password, user.Password)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if err := s.loginGuard.RecordFailure(user.Email, clientIP); err != nil {
+			log.Printf("Failed to record failed password check: %v", err)
+		}
+		return ErrIncorrectPassword
+	}
+	return nil
+}
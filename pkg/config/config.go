@@ -1,23 +1,236 @@
 package config
 
 import (
+	"errors"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
 	DatabaseDSN string
 	JWTSecret   string
-	Port        string
-	GinMode     string
+	// JWTAlgorithm is HS256 (sign with JWTSecret), RS256 or EdDSA
+	JWTAlgorithm string
+	// JWTPrivateKeys and JWTPublicKeys are PEM file paths or inline PEM
+	// blocks. The first private key signs; every key verifies. When no
+	// private key is configured for RS256/EdDSA, keys are generated and
+	// stored in the database, and replaced every JWTKeyRotationInterval.
+	JWTPrivateKeys         []string
+	JWTPublicKeys          []string
+	JWTKeyRotationInterval time.Duration
+	Port                   string
+	GinMode                string
+	AccessTokenTTL         time.Duration
+	RefreshTokenTTL        time.Duration
+	// RevocationCacheTTL bounds how long a token revoked on another
+	// instance may still be accepted by this one
+	RevocationCacheTTL time.Duration
+	// PasswordMinLength is counted in characters, PasswordMaxLength in
+	// bytes and capped at bcrypt's 72-byte limit
+	PasswordMinLength int
+	PasswordMaxLength int
+	// PasswordRequiredClasses lists lower, upper, digit and/or symbol
+	PasswordRequiredClasses []string
+	PasswordCheckBreached   bool
+	// PasswordHashAlgorithm is argon2id or bcrypt. Hashes made with another
+	// algorithm or other parameters are replaced at the next login.
+	PasswordHashAlgorithm string
+	BcryptCost            int
+	Argon2Memory          int // KiB
+	Argon2Time            int
+	Argon2Threads         int
+	// SessionCookiesEnabled lets browser clients receive their tokens as
+	// HttpOnly cookies by sending X-Session-Mode: cookie
+	SessionCookiesEnabled bool
+	SessionCookieDomain   string
+	SessionCookieSecure   bool
+	// SessionCookieSameSite is lax, strict or none
+	SessionCookieSameSite string
+	// CORSAllowedOrigins lists origins allowed to call the API: exact
+	// origins, https://*.example.com for any subdomain, or "*" for every
+	// origin. Credentials are never allowed through "*".
+	CORSAllowedOrigins   []string
+	CORSAllowedMethods   []string
+	CORSAllowedHeaders   []string
+	CORSExposedHeaders   []string
+	CORSMaxAge           time.Duration
+	CORSAllowCredentials bool
+	// AppBaseURL is the public URL of the frontend, used to build links in emails
+	AppBaseURL       string
+	PasswordResetTTL time.Duration
+	// PasswordResetRateLimit is how many reset links one account may be
+	// sent per PasswordResetRateWindow
+	PasswordResetRateLimit  int
+	PasswordResetRateWindow time.Duration
+	MagicLinkTTL            time.Duration
+	// MagicLinkRateLimit is how many login links one address may request
+	// per MagicLinkRateWindow
+	MagicLinkRateLimit  int
+	MagicLinkRateWindow time.Duration
+	// RequireEmailVerification keeps unverified users out of task routes
+	RequireEmailVerification bool
+	EmailVerificationTTL     time.Duration
+	// VerificationResendInterval is the minimum time between verification emails
+	VerificationResendInterval time.Duration
+	// TaskStatusTransitions maps each task status to the statuses it may
+	// move to. Nil means the default workflow.
+	TaskStatusTransitions map[string][]string
+	// TaskMaxDepth is how many levels of subtasks a task tree may have,
+	// counting the top-level task
+	TaskMaxDepth int
+	// ReminderInterval is how often due task reminders are looked for
+	ReminderInterval time.Duration
+	// CronSecret authorizes the /api/cron job endpoints, which are not
+	// served without it
+	CronSecret string
+	// AccountDeletionGracePeriod is how long a deleted account can still be restored
+	AccountDeletionGracePeriod time.Duration
+	// AdminEmails are given the admin role when they register or log in
+	AdminEmails []string
+	// LoginAttemptStore is "postgres" (shared by all instances) or "memory"
+	LoginAttemptStore       string
+	LoginMaxAccountFailures int
+	LoginMaxIPFailures      int
+	LoginLockoutDuration    time.Duration
+	LoginFailureWindow      time.Duration
+	LoginBackoffBase        time.Duration
+	LoginBackoffMax         time.Duration
+	// TrustedProxies lists proxies whose X-Forwarded-For header is believed
+	// when determining the client IP
+	TrustedProxies []string
+	// PATMaxLifetime caps the expiry users may choose for personal access tokens
+	PATMaxLifetime time.Duration
+	// TOTPIssuer is the account issuer shown in authenticator apps
+	TOTPIssuer   string
+	MailDriver   string
+	MailFrom     string
+	MailLogFile  string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	// OIDCRedirectURL is the frontend page providers send the user back to;
+	// it forwards code and state to /api/auth/oidc/callback
+	OIDCRedirectURL string
+	OIDCProviders   []OIDCProvider
+}
+
+// OIDCProvider configures one OpenID Connect login provider
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
 }
 
 func LoadConfig() *Config {
+	appBaseURL := getEnv("APP_BASE_URL", "http://localhost:3000")
+
 	return &Config{
-		DatabaseDSN: getEnv("DB_DSN", ""),
-		JWTSecret:   getEnv("JWT_SECRET", "default-secret-key"),
-		Port:        getEnv("PORT", "8080"),
-		GinMode:     getEnv("GIN_MODE", "debug"),
+		DatabaseDSN:                getEnv("DB_DSN", ""),
+		JWTSecret:                  getEnv("JWT_SECRET", ""),
+		JWTAlgorithm:               getEnv("JWT_ALGORITHM", "HS256"),
+		JWTPrivateKeys:             getEnvList("JWT_PRIVATE_KEYS"),
+		JWTPublicKeys:              getEnvList("JWT_PUBLIC_KEYS"),
+		JWTKeyRotationInterval:     getEnvDuration("JWT_KEY_ROTATION_INTERVAL", 30*24*time.Hour),
+		Port:                       getEnv("PORT", "8080"),
+		GinMode:                    getEnv("GIN_MODE", "debug"),
+		AccessTokenTTL:             getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL:            getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		RevocationCacheTTL:         getEnvDuration("REVOCATION_CACHE_TTL", 30*time.Second),
+		PasswordMinLength:          getEnvInt("PASSWORD_MIN_LENGTH", 10),
+		PasswordMaxLength:          getEnvInt("PASSWORD_MAX_LENGTH", 72),
+		PasswordRequiredClasses:    getEnvList("PASSWORD_REQUIRED_CLASSES"),
+		PasswordCheckBreached:      getEnvBool("PASSWORD_CHECK_BREACHED", true),
+		PasswordHashAlgorithm:      getEnv("PASSWORD_HASH_ALGORITHM", "argon2id"),
+		BcryptCost:                 getEnvInt("BCRYPT_COST", 12),
+		Argon2Memory:               getEnvInt("ARGON2_MEMORY", 19456),
+		Argon2Time:                 getEnvInt("ARGON2_TIME", 2),
+		Argon2Threads:              getEnvInt("ARGON2_THREADS", 1),
+		SessionCookiesEnabled:      getEnvBool("SESSION_COOKIES_ENABLED", false),
+		SessionCookieDomain:        getEnv("SESSION_COOKIE_DOMAIN", ""),
+		SessionCookieSecure:        getEnvBool("SESSION_COOKIE_SECURE", true),
+		SessionCookieSameSite:      getEnv("SESSION_COOKIE_SAMESITE", "lax"),
+		CORSAllowedOrigins:         getEnvListDefault("CORS_ALLOWED_ORIGINS", []string{appBaseURL}),
+		CORSAllowedMethods:         getEnvListDefault("CORS_ALLOWED_METHODS", []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}),
+		CORSAllowedHeaders:         getEnvListDefault("CORS_ALLOWED_HEADERS", []string{"Authorization", "Content-Type", "Accept", "Cache-Control", "X-Requested-With", "X-CSRF-Token", "X-Session-Mode"}),
+		CORSExposedHeaders:         getEnvListDefault("CORS_EXPOSED_HEADERS", []string{"Retry-After", "Content-Disposition"}),
+		CORSMaxAge:                 getEnvDuration("CORS_MAX_AGE", 12*time.Hour),
+		CORSAllowCredentials:       getEnvBool("CORS_ALLOW_CREDENTIALS", true),
+		AppBaseURL:                 appBaseURL,
+		PasswordResetTTL:           getEnvDuration("PASSWORD_RESET_TTL", time.Hour),
+		PasswordResetRateLimit:     getEnvInt("PASSWORD_RESET_RATE_LIMIT", 3),
+		PasswordResetRateWindow:    getEnvDuration("PASSWORD_RESET_RATE_WINDOW", time.Hour),
+		MagicLinkTTL:               getEnvDuration("MAGIC_LINK_TTL", 15*time.Minute),
+		MagicLinkRateLimit:         getEnvInt("MAGIC_LINK_RATE_LIMIT", 3),
+		MagicLinkRateWindow:        getEnvDuration("MAGIC_LINK_RATE_WINDOW", 15*time.Minute),
+		RequireEmailVerification:   getEnvBool("REQUIRE_EMAIL_VERIFICATION", false),
+		EmailVerificationTTL:       getEnvDuration("EMAIL_VERIFICATION_TTL", 48*time.Hour),
+		VerificationResendInterval: getEnvDuration("VERIFICATION_RESEND_INTERVAL", time.Minute),
+		TaskStatusTransitions:      loadTaskStatusTransitions(),
+		TaskMaxDepth:               getEnvInt("TASK_MAX_DEPTH", 5),
+		ReminderInterval:           getEnvDuration("REMINDER_INTERVAL", time.Minute),
+		CronSecret:                 getEnv("CRON_SECRET", ""),
+		AccountDeletionGracePeriod: getEnvDuration("ACCOUNT_DELETION_GRACE_PERIOD", 30*24*time.Hour),
+		AdminEmails:                getEnvList("ADMIN_EMAILS"),
+		LoginAttemptStore:          getEnv("LOGIN_ATTEMPT_STORE", "postgres"),
+		LoginMaxAccountFailures:    getEnvInt("LOGIN_MAX_ACCOUNT_FAILURES", 5),
+		LoginMaxIPFailures:         getEnvInt("LOGIN_MAX_IP_FAILURES", 20),
+		LoginLockoutDuration:       getEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		LoginFailureWindow:         getEnvDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		LoginBackoffBase:           getEnvDuration("LOGIN_BACKOFF_BASE", time.Second),
+		LoginBackoffMax:            getEnvDuration("LOGIN_BACKOFF_MAX", 30*time.Second),
+		TrustedProxies:             getEnvList("TRUSTED_PROXIES"),
+		PATMaxLifetime:             getEnvDuration("PAT_MAX_LIFETIME", 365*24*time.Hour),
+		TOTPIssuer:                 getEnv("TOTP_ISSUER", "Task Manager"),
+		MailDriver:                 getEnv("MAIL_DRIVER", "log"),
+		MailFrom:                   getEnv("MAIL_FROM", "no-reply@localhost"),
+		MailLogFile:                getEnv("MAIL_LOG_FILE", ""),
+		SMTPHost:                   getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:                   getEnv("SMTP_PORT", "587"),
+		SMTPUsername:               getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:               getEnv("SMTP_PASSWORD", ""),
+		OIDCRedirectURL:            getEnv("OIDC_REDIRECT_URL", appBaseURL+"/auth/callback"),
+		OIDCProviders:              loadOIDCProviders(),
+	}
+}
+
+// publicSecrets are JWT_SECRET values published with the project, which
+// must never be used to sign tokens or derive keys
+var publicSecrets = []string{"default-secret-key", "your-super-secret-jwt-key-change-in-production"}
+
+// Validate reports settings that make the server unsafe to start
+func (c *Config) Validate() error {
+	if c.JWTSecret == "" {
+		return errors.New("JWT_SECRET must be set")
+	}
+	for _, secret := range publicSecrets {
+		if c.JWTSecret == secret {
+			return errors.New("JWT_SECRET must be changed from the example value")
+		}
 	}
+	return nil
+}
+
+// loadOIDCProviders reads the providers named in OIDC_PROVIDERS. Each name
+// is configured through OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET and
+// optionally _SCOPES.
+func loadOIDCProviders() []OIDCProvider {
+	var providers []OIDCProvider
+	for _, name := range getEnvList("OIDC_PROVIDERS") {
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		providers = append(providers, OIDCProvider{
+			Name:         strings.ToLower(name),
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			Scopes:       getEnvList(prefix + "SCOPES"),
+		})
+	}
+	return providers
 }
 
 func getEnv(key, defaultValue string) string {
@@ -26,3 +239,74 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+// getEnvList splits a comma-separated variable, dropping empty entries
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// loadTaskStatusTransitions reads TASK_STATUS_TRANSITIONS, written as
+// "todo:in_progress,done;in_progress:done;done:todo". Statuses without an
+// entry are final.
+func loadTaskStatusTransitions() map[string][]string {
+	value := os.Getenv("TASK_STATUS_TRANSITIONS")
+	if value == "" {
+		return nil
+	}
+	transitions := make(map[string][]string)
+	for _, entry := range strings.Split(value, ";") {
+		from, to, _ := strings.Cut(entry, ":")
+		from = strings.TrimSpace(from)
+		if from == "" {
+			continue
+		}
+		transitions[from] = nil
+		for _, status := range strings.Split(to, ",") {
+			if status = strings.TrimSpace(status); status != "" {
+				transitions[from] = append(transitions[from], status)
+			}
+		}
+	}
+	return transitions
+}
+
+// getEnvListDefault is getEnvList with a default for unset or empty variables
+func getEnvListDefault(key string, defaultValue []string) []string {
+	if values := getEnvList(key); len(values) > 0 {
+		return values
+	}
+	return defaultValue
+}
@@ -17,16 +17,76 @@ func NewPostgresDB(dsn string) *gorm.DB {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
+	if err := migrateTaskOwner(db); err != nil {
+		log.Fatal("Failed to migrate task owners:", err)
+	}
+
 	// Auto-migrate the schema
-	err = db.AutoMigrate(&domain.Task{}, &domain.User{})
+	err = db.AutoMigrate(&domain.Task{}, &domain.User{}, &domain.RefreshToken{}, &domain.RevokedToken{}, &domain.UserTokenRevocation{}, &domain.PasswordResetToken{}, &domain.RecoveryCode{}, &domain.PersonalAccessToken{}, &domain.SigningKey{}, &domain.AuditEvent{}, &domain.LoginAttempt{}, &domain.OIDCLoginState{}, &domain.UserIdentity{}, &domain.MagicLinkToken{}, &domain.Session{}, &domain.Label{}, &domain.Project{})
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
+	if err := migrateTaskStatus(db); err != nil {
+		log.Fatal("Failed to migrate task statuses:", err)
+	}
+	if err := migrateEmailCase(db); err != nil {
+		log.Fatal("Failed to normalize email addresses:", err)
+	}
 
 	log.Println("Database connected successfully")
 	return db
 }
 
+// migrateTaskOwner deletes tasks that belong to no existing user, which are
+// left over from before tasks had owners, so that AutoMigrate can make
+// user_id NOT NULL and reference users. Nobody can be credited with them,
+// and scoped routes would never show them anyway.
+func migrateTaskOwner(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&domain.Task{}) {
+		return nil
+	}
+
+	columns, err := migrator.ColumnTypes(&domain.Task{})
+	if err != nil {
+		return err
+	}
+	query := "DELETE FROM tasks"
+	for _, column := range columns {
+		if column.Name() != "user_id" {
+			continue
+		}
+		if nullable, ok := column.Nullable(); ok && !nullable {
+			return nil
+		}
+		query = "DELETE FROM tasks WHERE user_id IS NULL OR user_id NOT IN (SELECT id FROM users)"
+	}
+
+	result := db.Exec(query)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Deleted %d tasks without an owner", result.RowsAffected)
+	}
+	return nil
+}
+
+// migrateTaskStatus carries the completed flag of existing tasks over to
+// their status and then drops the column, so it only ever runs once
+func migrateTaskStatus(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&domain.Task{}, "completed") {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("UPDATE tasks SET status = ?, completed_at = created_at WHERE completed", domain.TaskStatusDone).Error
+		if err != nil {
+			return err
+		}
+		return tx.Migrator().DropColumn(&domain.Task{}, "completed")
+	})
+}
+
 func HealthCheck(db *gorm.DB) error {
 	sqlDB, err := db.DB()
 	if err != nil {
@@ -34,3 +94,20 @@ func HealthCheck(db *gorm.DB) error {
 	}
 	return sqlDB.Ping()
 }
+
+// migrateEmailCase lowercases stored email addresses and adds a unique index
+// on the lowercase form, so that one address cannot be registered twice in
+// different case. It fails if such duplicates already exist; they have to
+// be merged by hand.
+func migrateEmailCase(db *gorm.DB) error {
+	if db.Migrator().HasIndex(&domain.User{}, "idx_users_email_lower") {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("UPDATE users SET email = LOWER(TRIM(email)) WHERE email <> LOWER(TRIM(email))").Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec("CREATE UNIQUE INDEX idx_users_email_lower ON users (LOWER(email))").Error
+	})
+}
@@ -0,0 +1,159 @@
+// Package hasher hashes passwords into self-describing strings, so the
+// algorithm and its parameters can change without invalidating stored
+// hashes. Argon2id hashes use the PHC string format
+// ($argon2id$v=19$m=...,t=...,p=...$salt$hash); bcrypt keeps its own
+// modular crypt format ($2a$cost$...), which is what existing rows hold.
+package hasher
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"dummy-backend/pkg/config"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Supported algorithms
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var ErrUnknownHash = errors.New("unrecognized password hash format")
+
+// Hasher creates and checks password hashes. Verify accepts hashes of every
+// supported algorithm and reports whether the hash should be replaced,
+// because it uses another algorithm or other parameters than Hash would.
+type Hasher interface {
+	Hash(password string) (string, error)
+	Verify(password, encoded string) (ok, needsRehash bool, err error)
+}
+
+// Argon2Params are the argon2id cost parameters. Memory is in KiB.
+type Argon2Params struct {
+	Memory  uint32
+	Time    uint32
+	Threads uint8
+}
+
+type hasher struct {
+	algorithm  string
+	bcryptCost int
+	argon2     Argon2Params
+}
+
+// New returns a hasher that creates hashes with cfg.PasswordHashAlgorithm
+func New(cfg *config.Config) (Hasher, error) {
+	if cfg.Argon2Memory < 0 || cfg.Argon2Time < 0 || cfg.Argon2Threads < 0 || cfg.Argon2Threads > 255 {
+		return nil, errors.New("argon2id parameters out of range")
+	}
+	h := &hasher{
+		algorithm:  cfg.PasswordHashAlgorithm,
+		bcryptCost: cfg.BcryptCost,
+		argon2: Argon2Params{
+			Memory:  uint32(cfg.Argon2Memory),
+			Time:    uint32(cfg.Argon2Time),
+			Threads: uint8(cfg.Argon2Threads),
+		},
+	}
+
+	switch h.algorithm {
+	case Bcrypt:
+		if h.bcryptCost < bcrypt.MinCost || h.bcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case Argon2id:
+		if h.argon2.Memory < 8*uint32(h.argon2.Threads) || h.argon2.Time < 1 || h.argon2.Threads < 1 {
+			return nil, errors.New("argon2id needs time >= 1, threads >= 1 and memory >= 8 KiB per thread")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm %q", h.algorithm)
+	}
+	return h, nil
+}
+
+func (h *hasher) Hash(password string) (string, error) {
+	if h.algorithm == Bcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		return string(hash), err
+	}
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	p := h.argon2
+	key := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, argon2KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Time, p.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *hasher) Verify(password, encoded string) (bool, bool, error) {
+	switch {
+	case encoded == "":
+		// Accounts without a local password never match
+		return false, false, nil
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return h.verifyArgon2id(password, encoded)
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		return h.verifyBcrypt(password, encoded)
+	default:
+		return false, false, ErrUnknownHash
+	}
+}
+
+func (h *hasher) verifyBcrypt(password, encoded string) (bool, bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil {
+		return false, false, err
+	}
+	return true, h.algorithm != Bcrypt || cost != h.bcryptCost, nil
+}
+
+func (h *hasher) verifyArgon2id(password, encoded string) (bool, bool, error) {
+	// $argon2id$v=19$m=65536,t=3,p=2$salt$hash splits into 6 parts
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return false, false, ErrUnknownHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false, ErrUnknownHash
+	}
+	var p Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+		return false, false, ErrUnknownHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, ErrUnknownHash
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(want) == 0 {
+		return false, false, ErrUnknownHash
+	}
+
+	got := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, uint32(len(want)))
+	if subtle.ConstantTimeCompare(got, want) != 1 {
+		return false, false, nil
+	}
+	return true, h.algorithm != Argon2id || p != h.argon2 || len(want) != argon2KeyLength, nil
+}
@@ -0,0 +1,139 @@
+package hasher
+
+import (
+	"dummy-backend/pkg/config"
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTestHasher returns a hasher with cheap parameters, adjusted by configure
+func newTestHasher(t *testing.T, configure func(cfg *config.Config)) Hasher {
+	t.Helper()
+	cfg := &config.Config{
+		PasswordHashAlgorithm: Argon2id,
+		BcryptCost:            bcrypt.MinCost,
+		Argon2Memory:          64,
+		Argon2Time:            1,
+		Argon2Threads:         1,
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+	h, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return h
+}
+
+func useBcrypt(cfg *config.Config) { cfg.PasswordHashAlgorithm = Bcrypt }
+
+func TestHashAndVerify(t *testing.T) {
+	for _, algorithm := range []string{Argon2id, Bcrypt} {
+		h := newTestHasher(t, func(cfg *config.Config) { cfg.PasswordHashAlgorithm = algorithm })
+
+		encoded, err := h.Hash("correct horse battery staple")
+		if err != nil {
+			t.Fatalf("%s: Hash: %v", algorithm, err)
+		}
+		if algorithm == Argon2id && !strings.HasPrefix(encoded, "$argon2id$v=19$m=64,t=1,p=1$") {
+			t.Errorf("argon2id hash = %q, want the PHC format with the configured parameters", encoded)
+		}
+		if other, _ := h.Hash("correct horse battery staple"); other == encoded {
+			t.Errorf("%s: hashing twice gave the same hash, want a fresh salt", algorithm)
+		}
+
+		ok, rehash, err := h.Verify("correct horse battery staple", encoded)
+		if err != nil || !ok || rehash {
+			t.Errorf("%s: Verify(right password) = (%v, %v, %v), want (true, false, nil)", algorithm, ok, rehash, err)
+		}
+		ok, rehash, err = h.Verify("wrong password", encoded)
+		if err != nil || ok || rehash {
+			t.Errorf("%s: Verify(wrong password) = (%v, %v, %v), want (false, false, nil)", algorithm, ok, rehash, err)
+		}
+	}
+}
+
+func TestVerifyFlagsRehash(t *testing.T) {
+	argon2Hash, err := newTestHasher(t, nil).Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcryptHash, err := newTestHasher(t, useBcrypt).Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		configure func(cfg *config.Config)
+		encoded   string
+		want      bool
+	}{
+		{name: "argon2id with the same parameters", encoded: argon2Hash, want: false},
+		{name: "argon2id with more memory configured", configure: func(cfg *config.Config) { cfg.Argon2Memory = 128 }, encoded: argon2Hash, want: true},
+		{name: "argon2id with more iterations configured", configure: func(cfg *config.Config) { cfg.Argon2Time = 2 }, encoded: argon2Hash, want: true},
+		{name: "argon2id when bcrypt is configured", configure: useBcrypt, encoded: argon2Hash, want: true},
+		{name: "bcrypt with the same cost", configure: useBcrypt, encoded: bcryptHash, want: false},
+		{name: "bcrypt with a higher cost configured", configure: func(cfg *config.Config) { useBcrypt(cfg); cfg.BcryptCost = bcrypt.MinCost + 1 }, encoded: bcryptHash, want: true},
+		{name: "bcrypt when argon2id is configured", encoded: bcryptHash, want: true},
+	}
+	for _, tt := range tests {
+		ok, rehash, err := newTestHasher(t, tt.configure).Verify("secret", tt.encoded)
+		if err != nil || !ok {
+			t.Fatalf("%s: Verify = (%v, %v), want a match", tt.name, ok, err)
+		}
+		if rehash != tt.want {
+			t.Errorf("%s: needsRehash = %v, want %v", tt.name, rehash, tt.want)
+		}
+	}
+}
+
+func TestVerifyRejectsMalformedHashes(t *testing.T) {
+	h := newTestHasher(t, nil)
+
+	if ok, _, err := h.Verify("secret", ""); ok || err != nil {
+		t.Errorf("Verify with no hash = (%v, %v), want no match and no error", ok, err)
+	}
+
+	malformed := []string{
+		"plaintext",
+		"$argon2i$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$aGFzaA",
+		"$argon2id$v=18$m=64,t=1,p=1$c2FsdHNhbHQ$aGFzaA",
+		"$argon2id$v=19$m=64$c2FsdHNhbHQ$aGFzaA",
+		"$argon2id$v=19$m=64,t=1,p=1$not base64$aGFzaA",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ",
+	}
+	for _, encoded := range malformed {
+		if ok, _, err := h.Verify("secret", encoded); ok || !errors.Is(err, ErrUnknownHash) {
+			t.Errorf("Verify(%q) = (%v, %v), want ErrUnknownHash", encoded, ok, err)
+		}
+	}
+}
+
+func TestNewRejectsBadParameters(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(cfg *config.Config)
+	}{
+		{name: "unknown algorithm", configure: func(cfg *config.Config) { cfg.PasswordHashAlgorithm = "md5" }},
+		{name: "bcrypt cost too low", configure: func(cfg *config.Config) { useBcrypt(cfg); cfg.BcryptCost = bcrypt.MinCost - 1 }},
+		{name: "bcrypt cost too high", configure: func(cfg *config.Config) { useBcrypt(cfg); cfg.BcryptCost = bcrypt.MaxCost + 1 }},
+		{name: "argon2id without iterations", configure: func(cfg *config.Config) { cfg.Argon2Time = 0 }},
+		{name: "argon2id without threads", configure: func(cfg *config.Config) { cfg.Argon2Threads = 0 }},
+		{name: "argon2id with too many threads", configure: func(cfg *config.Config) { cfg.Argon2Threads = 256 }},
+		{name: "argon2id with too little memory", configure: func(cfg *config.Config) { cfg.Argon2Memory = 7 }},
+		{name: "negative argon2id memory", configure: func(cfg *config.Config) { cfg.Argon2Memory = -1 }},
+	}
+	for _, tt := range tests {
+		cfg := &config.Config{PasswordHashAlgorithm: Argon2id, BcryptCost: bcrypt.DefaultCost, Argon2Memory: 64, Argon2Time: 1, Argon2Threads: 1}
+		tt.configure(cfg)
+		if _, err := New(cfg); err == nil {
+			t.Errorf("%s: New succeeded, want an error", tt.name)
+		}
+	}
+}
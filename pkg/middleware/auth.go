@@ -1,7 +1,9 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"dummy-backend/lib/service"
+	"dummy-backend/pkg/sessioncookie"
 	"net/http"
 	"strings"
 
@@ -9,51 +11,160 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func AuthMiddleware(authService service.AuthService) gin.HandlerFunc {
+// AuthMiddleware authenticates the bearer token. JWT access tokens are
+// always accepted; personal access tokens are accepted only when patService
+// is non-nil, and then carry the token's scopes for RequireScope. Pass nil
+// for routes that manage the account itself.
+//
+// Without an Authorization header the access token is read from the session
+// cookie, if cookies is non-nil and cookie sessions are enabled. Unsafe
+// requests authenticated by cookie must pass the CSRF check.
+func AuthMiddleware(authService service.AuthService, patService service.PersonalAccessTokenService, cookies *sessioncookie.Cookies) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		var tokenString string
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+			tokenString = cookies.AccessToken(c)
+			if tokenString == "" {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+				c.Abort()
+				return
+			}
+			if !cookies.ValidCSRF(c) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "CSRF token missing or invalid"})
+				c.Abort()
+				return
+			}
+		} else {
+			tokenString = strings.TrimPrefix(authHeader, "Bearer ")
+			if tokenString == authHeader {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Bearer token required"})
+				c.Abort()
+				return
+			}
+		}
+
+		// Cookies only ever hold JWT access tokens
+		if authHeader != "" && strings.HasPrefix(tokenString, service.PersonalAccessTokenPrefix) {
+			if patService == nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Personal access tokens are not accepted here"})
+				c.Abort()
+				return
+			}
+			pat, err := patService.Authenticate(tokenString)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+				c.Abort()
+				return
+			}
+			c.Set("user_id", pat.UserID)
+			c.Set("scopes", pat.Scopes)
+			c.Set("role", pat.User.Role)
+			c.Set("email_verified", pat.User.VerifiedAt != nil)
+			c.Next()
+			return
+		}
+
+		token, err := authService.ValidateToken(tokenString)
+		if err != nil || !token.Valid {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Bearer token required"})
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
-		token, err := authService.ValidateToken(tokenString)
-		if err != nil || !token.Valid {
+		// JSON numbers decode as float64; handlers read the ID with c.GetUint
+		userID, ok := claims["user_id"].(float64)
+		if !ok || userID <= 0 {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
+		c.Set("user_id", uint(userID))
+		c.Set("jti", claims["jti"])
+		c.Set("sid", claims["sid"])
+		c.Set("email_verified", claims["email_verified"] == true)
+		if role, ok := claims["role"].(string); ok {
+			c.Set("role", role)
+		}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			if userID, exists := claims["user_id"]; exists {
-				c.Set("user_id", userID)
-			}
+		c.Next()
+	}
+}
+
+// RequireVerifiedEmail rejects users who have not confirmed their email
+// address. It must run after AuthMiddleware. The claim is refreshed whenever
+// a new access token is issued, so a user who has just verified may need to
+// call /api/auth/refresh first.
+func RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool("email_verified") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Email address not verified"})
+			c.Abort()
+			return
 		}
 
 		c.Next()
 	}
 }
 
-func CORSMiddleware() gin.HandlerFunc {
+// RequireRole rejects callers whose role is not one of roles. It must run
+// after AuthMiddleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Header("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		if !containsString(roles, c.GetString("role")) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
+// RequireScope rejects personal access tokens that were not granted scope.
+// JWT sessions carry no scopes and are allowed everything.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if value, ok := c.Get("scopes"); ok {
+			scopes, _ := value.([]string)
+			if !containsString(scopes, scope) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Token lacks required scope " + scope})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// RequireCronSecret admits only requests carrying secret as bearer token,
+// which is how Vercel authenticates its cron jobs
+func RequireCronSecret(secret string) gin.HandlerFunc {
+	want := []byte("Bearer " + secret)
+	return func(c *gin.Context) {
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), want) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid cron secret"})
+			c.Abort()
 			return
 		}
 
 		c.Next()
 	}
 }
+
+func containsString(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
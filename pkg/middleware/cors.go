@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"dummy-backend/pkg/config"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// originPattern is an allowed origin. "*" allows every origin; a "*" in
+// place of the leftmost host labels, as in https://*.example.com, allows
+// any subdomain but not the domain itself.
+type originPattern struct {
+	prefix, suffix string
+	wildcard       bool
+}
+
+func parseOriginPattern(pattern string) originPattern {
+	pattern = strings.ToLower(strings.TrimSuffix(pattern, "/"))
+	if pattern == "*" {
+		return originPattern{wildcard: true}
+	}
+	if i := strings.Index(pattern, "://*."); i >= 0 {
+		return originPattern{prefix: pattern[:i+3], suffix: pattern[i+4:], wildcard: true}
+	}
+	return originPattern{prefix: pattern}
+}
+
+// matches reports whether origin is allowed; origin must be lower case
+func (p originPattern) matches(origin string) bool {
+	if !p.wildcard {
+		return origin == p.prefix
+	}
+	if p.prefix == "" && p.suffix == "" {
+		return true
+	}
+	if !strings.HasPrefix(origin, p.prefix) || !strings.HasSuffix(origin, p.suffix) {
+		return false
+	}
+	labels := strings.TrimSuffix(strings.TrimPrefix(origin, p.prefix), p.suffix)
+	return labels != "" && !strings.ContainsAny(labels, "/:@")
+}
+
+// CORSMiddleware applies the CORS policy from cfg. A matched origin is
+// reflected in Access-Control-Allow-Origin; other origins get no CORS
+// headers, so browsers block them. Credentials are only allowed for origins
+// matched by an explicit pattern, never through a bare "*".
+func CORSMiddleware(cfg *config.Config) gin.HandlerFunc {
+	patterns := make([]originPattern, 0, len(cfg.CORSAllowedOrigins))
+	for _, origin := range cfg.CORSAllowedOrigins {
+		patterns = append(patterns, parseOriginPattern(origin))
+	}
+	allowMethods := strings.Join(cfg.CORSAllowedMethods, ", ")
+	allowHeaders := strings.Join(cfg.CORSAllowedHeaders, ", ")
+	exposeHeaders := strings.Join(cfg.CORSExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.CORSMaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		// Responses differ by origin, so caches must not share them
+		c.Writer.Header().Add("Vary", "Origin")
+
+		origin := c.GetHeader("Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		if origin != "" {
+			allowed, credentials := false, false
+			lower := strings.ToLower(origin)
+			for _, p := range patterns {
+				if p.matches(lower) {
+					allowed = true
+					credentials = credentials || p.prefix != "" || p.suffix != ""
+				}
+			}
+
+			if allowed {
+				c.Header("Access-Control-Allow-Origin", origin)
+				if cfg.CORSAllowCredentials && credentials {
+					c.Header("Access-Control-Allow-Credentials", "true")
+				}
+				if preflight {
+					c.Header("Access-Control-Allow-Methods", allowMethods)
+					c.Header("Access-Control-Allow-Headers", allowHeaders)
+					if cfg.CORSMaxAge > 0 {
+						c.Header("Access-Control-Max-Age", maxAge)
+					}
+				} else if exposeHeaders != "" {
+					c.Header("Access-Control-Expose-Headers", exposeHeaders)
+				}
+			}
+		}
+
+		if c.Request.Method == http.MethodOptions {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,204 @@
+package middleware
+
+import (
+	"dummy-backend/pkg/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	base := config.Config{
+		CORSAllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+		CORSAllowedMethods:   []string{"GET", "POST"},
+		CORSAllowedHeaders:   []string{"Authorization", "Content-Type"},
+		CORSExposedHeaders:   []string{"Retry-After"},
+		CORSMaxAge:           time.Hour,
+		CORSAllowCredentials: true,
+	}
+
+	tests := []struct {
+		name string
+		// configure adjusts a copy of base
+		configure func(cfg *config.Config)
+		origin    string
+		preflight bool
+
+		wantStatus      int
+		wantOrigin      string
+		wantCredentials string
+		wantMethods     string
+		wantMaxAge      string
+		wantExpose      string
+	}{
+		{
+			name:            "exact match",
+			origin:          "https://app.example.com",
+			wantStatus:      http.StatusOK,
+			wantOrigin:      "https://app.example.com",
+			wantCredentials: "true",
+			wantExpose:      "Retry-After",
+		},
+		{
+			name:            "exact match ignores case",
+			origin:          "https://APP.example.com",
+			wantStatus:      http.StatusOK,
+			wantOrigin:      "https://APP.example.com",
+			wantCredentials: "true",
+			wantExpose:      "Retry-After",
+		},
+		{
+			name:            "wildcard subdomain",
+			origin:          "https://tenant.example.org",
+			wantStatus:      http.StatusOK,
+			wantOrigin:      "https://tenant.example.org",
+			wantCredentials: "true",
+			wantExpose:      "Retry-After",
+		},
+		{
+			name:            "wildcard nested subdomain",
+			origin:          "https://a.b.example.org",
+			wantStatus:      http.StatusOK,
+			wantOrigin:      "https://a.b.example.org",
+			wantCredentials: "true",
+			wantExpose:      "Retry-After",
+		},
+		{
+			name:       "wildcard does not match the bare domain",
+			origin:     "https://example.org",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "wildcard does not match another scheme",
+			origin:     "http://tenant.example.org",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "wildcard does not match a lookalike domain",
+			origin:     "https://evil-example.org",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "wildcard does not match userinfo tricks",
+			origin:     "https://evil.com@x.example.org",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "unlisted origin",
+			origin:     "https://evil.example.com",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "no origin",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:            "preflight",
+			origin:          "https://app.example.com",
+			preflight:       true,
+			wantStatus:      http.StatusNoContent,
+			wantOrigin:      "https://app.example.com",
+			wantCredentials: "true",
+			wantMethods:     "GET, POST",
+			wantMaxAge:      "3600",
+		},
+		{
+			name:       "preflight from unlisted origin",
+			origin:     "https://evil.example.com",
+			preflight:  true,
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:            "preflight without max age",
+			configure:       func(cfg *config.Config) { cfg.CORSMaxAge = 0 },
+			origin:          "https://app.example.com",
+			preflight:       true,
+			wantStatus:      http.StatusNoContent,
+			wantOrigin:      "https://app.example.com",
+			wantCredentials: "true",
+			wantMethods:     "GET, POST",
+		},
+		{
+			name:       "credentials disabled",
+			configure:  func(cfg *config.Config) { cfg.CORSAllowCredentials = false },
+			origin:     "https://app.example.com",
+			wantStatus: http.StatusOK,
+			wantOrigin: "https://app.example.com",
+			wantExpose: "Retry-After",
+		},
+		{
+			name:       "any origin without credentials",
+			configure:  func(cfg *config.Config) { cfg.CORSAllowedOrigins = []string{"*"} },
+			origin:     "https://elsewhere.example.net",
+			wantStatus: http.StatusOK,
+			wantOrigin: "https://elsewhere.example.net",
+			wantExpose: "Retry-After",
+		},
+		{
+			name:            "explicit entry grants credentials alongside any origin",
+			configure:       func(cfg *config.Config) { cfg.CORSAllowedOrigins = []string{"*", "https://app.example.com"} },
+			origin:          "https://app.example.com",
+			wantStatus:      http.StatusOK,
+			wantOrigin:      "https://app.example.com",
+			wantCredentials: "true",
+			wantExpose:      "Retry-After",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base
+			if tt.configure != nil {
+				tt.configure(&cfg)
+			}
+
+			router := gin.New()
+			router.Use(CORSMiddleware(&cfg))
+			router.GET("/resource", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			method := http.MethodGet
+			if tt.preflight {
+				method = http.MethodOptions
+			}
+			req := httptest.NewRequest(method, "/resource", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Values("Vary"); len(got) != 1 || got[0] != "Origin" {
+				t.Errorf("Vary = %q, want [Origin]", got)
+			}
+
+			headers := []struct{ name, want string }{
+				{"Access-Control-Allow-Origin", tt.wantOrigin},
+				{"Access-Control-Allow-Credentials", tt.wantCredentials},
+				{"Access-Control-Allow-Methods", tt.wantMethods},
+				{"Access-Control-Max-Age", tt.wantMaxAge},
+				{"Access-Control-Expose-Headers", tt.wantExpose},
+			}
+			for _, h := range headers {
+				if got := rec.Header().Get(h.name); got != h.want {
+					t.Errorf("%s = %q, want %q", h.name, got, h.want)
+				}
+			}
+			if tt.wantMethods != "" {
+				if got, want := rec.Header().Get("Access-Control-Allow-Headers"), "Authorization, Content-Type"; got != want {
+					t.Errorf("Access-Control-Allow-Headers = %q, want %q", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package oidc
+
+import (
+	"context"
+	"dummy-backend/pkg/keys"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID     = "client-id"
+	testClientSecret = "client-secret"
+	testRedirectURL  = "https://app.example.com/auth/callback"
+)
+
+// mockProvider is an in-process OpenID provider. It serves discovery, its
+// key set and a token endpoint that checks PKCE, and hands out codes for
+// authorization URLs through authorize.
+type mockProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *keys.Key
+
+	// discoveryIssuer and tokenIssuer override the issuer in the metadata
+	// and in ID tokens
+	discoveryIssuer string
+	tokenIssuer     string
+	// signingKey, when set, signs ID tokens instead of the published key
+	signingKey *keys.Key
+
+	mu    sync.Mutex
+	codes map[string]authRequest
+}
+
+type authRequest struct {
+	challenge string
+	nonce     string
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+	key, err := keys.Generate(keys.RS256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockProvider{t: t, key: key, codes: make(map[string]authRequest)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("/jwks", m.jwks)
+	mux.HandleFunc("/token", m.token)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func (m *mockProvider) issuer() string {
+	return m.server.URL
+}
+
+func (m *mockProvider) provider() *Provider {
+	return NewProvider(Config{
+		Name:         "mock",
+		Issuer:       m.issuer(),
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+	}, m.server.Client())
+}
+
+func (m *mockProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := m.issuer()
+	if m.discoveryIssuer != "" {
+		issuer = m.discoveryIssuer
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 issuer,
+		"authorization_endpoint": m.issuer() + "/authorize",
+		"token_endpoint":         m.issuer() + "/token",
+		"jwks_uri":               m.issuer() + "/jwks",
+	})
+}
+
+func (m *mockProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, keys.JSONWebKeySet{Keys: []keys.JSONWebKey{m.key.JWK()}})
+}
+
+// authorize plays the user approving the login at authURL and returns the
+// code the provider redirects back with
+func (m *mockProvider) authorize(authURL string) string {
+	m.t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	q := u.Query()
+	if u.Path != "/authorize" || q.Get("client_id") != testClientID || q.Get("redirect_uri") != testRedirectURL {
+		m.t.Fatalf("unexpected authorization URL %s", authURL)
+	}
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		m.t.Fatalf("authorization URL without S256 code challenge: %s", authURL)
+	}
+
+	code := "code-" + q.Get("state")
+	m.mu.Lock()
+	m.codes[code] = authRequest{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	m.mu.Unlock()
+	return code
+}
+
+func (m *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	clientID, secret, ok := r.BasicAuth()
+	if !ok || clientID != testClientID || secret != testClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" ||
+		r.PostForm.Get("redirect_uri") != testRedirectURL {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	m.mu.Lock()
+	req, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+	if !ok || CodeChallenge(r.PostForm.Get("code_verifier")) != req.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	issuer := m.issuer()
+	if m.tokenIssuer != "" {
+		issuer = m.tokenIssuer
+	}
+	key := m.key
+	if m.signingKey != nil {
+		key = m.signingKey
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            issuer,
+		"aud":            testClientID,
+		"sub":            "user-1",
+		"email":          "user@example.com",
+		"email_verified": true,
+		"name":           "Test User",
+		"nonce":          req.nonce,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["kid"] = key.ID
+	idToken, err := token.SignedString(key.Private)
+	if err != nil {
+		m.t.Error(err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"access_token": "unused", "token_type": "Bearer", "id_token": idToken})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestLogin(t *testing.T) {
+	otherKey, err := keys.Generate(keys.RS256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		// setup adjusts the provider before the login
+		setup func(m *mockProvider)
+		// exchangeVerifier and exchangeNonce replace the values the relying
+		// party kept, when set
+		exchangeVerifier string
+		exchangeNonce    string
+		wantErr          string
+		wantIDTokenErr   bool
+	}{
+		{name: "valid login"},
+		{name: "nonce mismatch", exchangeNonce: "another-nonce", wantIDTokenErr: true},
+		{name: "wrong PKCE verifier", exchangeVerifier: "another-verifier", wantErr: "invalid_grant"},
+		{
+			name:           "ID token from another issuer",
+			setup:          func(m *mockProvider) { m.tokenIssuer = "https://evil.example.com" },
+			wantIDTokenErr: true,
+		},
+		{
+			name:           "ID token signed with an unpublished key",
+			setup:          func(m *mockProvider) { m.signingKey = otherKey },
+			wantIDTokenErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockProvider(t)
+			if tt.setup != nil {
+				tt.setup(m)
+			}
+			p := m.provider()
+			ctx := context.Background()
+
+			verifier, nonce := "verifier-0123456789-0123456789-0123456789", "nonce-1"
+			authURL, err := p.AuthCodeURL(ctx, "state-1", nonce, CodeChallenge(verifier))
+			if err != nil {
+				t.Fatalf("AuthCodeURL: %v", err)
+			}
+			code := m.authorize(authURL)
+
+			if tt.exchangeVerifier != "" {
+				verifier = tt.exchangeVerifier
+			}
+			if tt.exchangeNonce != "" {
+				nonce = tt.exchangeNonce
+			}
+			claims, err := p.Exchange(ctx, code, verifier, nonce)
+
+			switch {
+			case tt.wantIDTokenErr:
+				if !errors.Is(err, ErrInvalidIDToken) {
+					t.Fatalf("Exchange error = %v, want ErrInvalidIDToken", err)
+				}
+			case tt.wantErr != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Exchange error = %v, want one containing %q", err, tt.wantErr)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("Exchange: %v", err)
+				}
+				want := Claims{Subject: "user-1", Email: "user@example.com", EmailVerified: true, Name: "Test User"}
+				if *claims != want {
+					t.Fatalf("claims = %+v, want %+v", *claims, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	m := newMockProvider(t)
+	m.discoveryIssuer = "https://evil.example.com"
+
+	_, err := m.provider().AuthCodeURL(context.Background(), "state", "nonce", CodeChallenge("verifier"))
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("AuthCodeURL error = %v, want issuer mismatch", err)
+	}
+}
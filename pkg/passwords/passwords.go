@@ -0,0 +1,34 @@
+// Package passwords checks candidate passwords against a list of the most
+// common passwords seen in public breaches. The list is embedded so the
+// check works offline.
+package passwords
+
+import (
+	_ "embed"
+	"strings"
+	"sync"
+)
+
+//go:embed common.txt
+var commonList string
+
+var (
+	loadOnce sync.Once
+	common   map[string]struct{}
+)
+
+// IsCommon reports whether password, ignoring case, is on the list
+func IsCommon(password string) bool {
+	loadOnce.Do(func() {
+		lines := strings.Split(commonList, "\n")
+		common = make(map[string]struct{}, len(lines))
+		for _, line := range lines {
+			if line = strings.TrimSpace(line); line != "" {
+				common[strings.ToLower(line)] = struct{}{}
+			}
+		}
+	})
+
+	_, ok := common[strings.ToLower(password)]
+	return ok
+}
@@ -0,0 +1,202 @@
+// Package sessioncookie keeps browser sessions in cookies instead of
+// JavaScript-readable storage. The access and refresh tokens are HttpOnly
+// cookies; a third, readable cookie holds a CSRF token that the client must
+// echo in the X-CSRF-Token header of unsafe requests (double-submit).
+//
+// It also writes the cookie that ties a provider login to the browser that
+// started it, which is used whether or not cookie sessions are enabled.
+package sessioncookie
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"dummy-backend/pkg/config"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cookie and header names
+const (
+	AccessTokenCookie  = "access_token"
+	RefreshTokenCookie = "refresh_token"
+	CSRFCookie         = "csrf_token"
+	CSRFHeader         = "X-CSRF-Token"
+	// ModeHeader set to "cookie" on a login or refresh request asks for the
+	// tokens as cookies rather than in the response body
+	ModeHeader = "X-Session-Mode"
+	// OIDCBindingCookie holds the secret a provider login's state is bound to
+	OIDCBindingCookie = "oidc_binding"
+)
+
+const (
+	// refreshCookiePath limits the refresh token to the endpoints that use it
+	refreshCookiePath = "/api/auth"
+	// oidcBindingCookiePath limits the binding cookie to the provider login
+	oidcBindingCookiePath = "/api/auth/oidc"
+)
+
+// Cookies writes and reads the session cookies. A nil *Cookies, or one
+// built from a config with cookie sessions disabled, never finds cookies on
+// a request and writes none.
+type Cookies struct {
+	enabled         bool
+	domain          string
+	secure          bool
+	sameSite        http.SameSite
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+}
+
+// New reads the cookie settings from cfg
+func New(cfg *config.Config) (*Cookies, error) {
+	var sameSite http.SameSite
+	switch strings.ToLower(cfg.SessionCookieSameSite) {
+	case "lax":
+		sameSite = http.SameSiteLaxMode
+	case "strict":
+		sameSite = http.SameSiteStrictMode
+	case "none":
+		// Browsers drop SameSite=None cookies that are not Secure
+		if !cfg.SessionCookieSecure {
+			return nil, errors.New("SameSite=None session cookies must be Secure")
+		}
+		sameSite = http.SameSiteNoneMode
+	default:
+		return nil, fmt.Errorf("unsupported SameSite mode %q", cfg.SessionCookieSameSite)
+	}
+
+	return &Cookies{
+		enabled:         cfg.SessionCookiesEnabled,
+		domain:          cfg.SessionCookieDomain,
+		secure:          cfg.SessionCookieSecure,
+		sameSite:        sameSite,
+		accessTokenTTL:  cfg.AccessTokenTTL,
+		refreshTokenTTL: cfg.RefreshTokenTTL,
+	}, nil
+}
+
+// Requested reports whether the client asked for cookie mode
+func (k *Cookies) Requested(c *gin.Context) bool {
+	return k != nil && k.enabled && strings.EqualFold(c.GetHeader(ModeHeader), "cookie")
+}
+
+// Write sets the token cookies and a fresh CSRF cookie, and returns the CSRF
+// token so that a frontend on another origin, which cannot read the cookie,
+// gets it too.
+func (k *Cookies) Write(c *gin.Context, accessToken, refreshToken string) (string, error) {
+	csrfToken, err := newCSRFToken()
+	if err != nil {
+		return "", err
+	}
+
+	k.set(c, AccessTokenCookie, accessToken, "/", k.accessTokenTTL, true)
+	k.set(c, RefreshTokenCookie, refreshToken, refreshCookiePath, k.refreshTokenTTL, true)
+	k.set(c, CSRFCookie, csrfToken, "/", k.refreshTokenTTL, false)
+	return csrfToken, nil
+}
+
+// Clear expires the session cookies
+func (k *Cookies) Clear(c *gin.Context) {
+	if k == nil || !k.enabled {
+		return
+	}
+	k.set(c, AccessTokenCookie, "", "/", -1, true)
+	k.set(c, RefreshTokenCookie, "", refreshCookiePath, -1, true)
+	k.set(c, CSRFCookie, "", "/", -1, false)
+}
+
+// AccessToken returns the access token cookie, or "" without one
+func (k *Cookies) AccessToken(c *gin.Context) string {
+	return k.get(c, AccessTokenCookie)
+}
+
+// RefreshToken returns the refresh token cookie, or "" without one
+func (k *Cookies) RefreshToken(c *gin.Context) string {
+	return k.get(c, RefreshTokenCookie)
+}
+
+// WriteOIDCBinding sets the binding cookie of a provider login that must be
+// completed within ttl. Unlike the session cookies it is written even with
+// cookie sessions disabled, but not by a nil *Cookies.
+func (k *Cookies) WriteOIDCBinding(c *gin.Context, binding string, ttl time.Duration) {
+	if k == nil {
+		return
+	}
+	k.set(c, OIDCBindingCookie, binding, oidcBindingCookiePath, ttl, true)
+}
+
+// OIDCBinding returns the binding cookie, or "" without one
+func (k *Cookies) OIDCBinding(c *gin.Context) string {
+	if k == nil {
+		return ""
+	}
+	value, err := c.Cookie(OIDCBindingCookie)
+	if err != nil {
+		return ""
+	}
+	return value
+}
+
+// ClearOIDCBinding expires the binding cookie
+func (k *Cookies) ClearOIDCBinding(c *gin.Context) {
+	if k == nil {
+		return
+	}
+	k.set(c, OIDCBindingCookie, "", oidcBindingCookiePath, -1, true)
+}
+
+// ValidCSRF reports whether the request may act on its cookies. Safe methods
+// always may; unsafe ones must repeat the CSRF cookie in the CSRF header,
+// which a cross-site form or script cannot do.
+func (k *Cookies) ValidCSRF(c *gin.Context) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	cookie := k.get(c, CSRFCookie)
+	header := c.GetHeader(CSRFHeader)
+	return cookie != "" && subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) == 1
+}
+
+func (k *Cookies) get(c *gin.Context, name string) string {
+	if k == nil || !k.enabled {
+		return ""
+	}
+	value, err := c.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return value
+}
+
+// set writes a cookie; a negative ttl deletes it
+func (k *Cookies) set(c *gin.Context, name, value, path string, ttl time.Duration, httpOnly bool) {
+	maxAge := int(ttl.Seconds())
+	if ttl < 0 {
+		maxAge = -1
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   k.domain,
+		MaxAge:   maxAge,
+		Secure:   k.secure,
+		HttpOnly: httpOnly,
+		SameSite: k.sameSite,
+	})
+}
+
+func newCSRFToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,107 @@
+package totp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors, "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeAtMatchesRFC6238(t *testing.T) {
+	// The RFC lists 8 digit codes; 6 digit codes are their last 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := CodeAt(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("CodeAt at %d: %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("CodeAt at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+
+	if lower, _ := CodeAt(strings.ToLower(rfcSecret), Step(time.Unix(59, 0))); lower != "287082" {
+		t.Errorf("CodeAt with a lowercase secret = %s, want 287082", lower)
+	}
+	if _, err := CodeAt("not base32!", 1); err == nil {
+		t.Error("CodeAt with an invalid secret succeeded, want an error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+	previous, _ := CodeAt(rfcSecret, step-1)
+	next, _ := CodeAt(rfcSecret, step+1)
+	later, _ := CodeAt(rfcSecret, step+2)
+
+	tests := []struct {
+		name     string
+		code     string
+		skew     int
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current step", code: "050471", skew: 1, wantStep: step, wantOK: true},
+		{name: "previous step within skew", code: previous, skew: 1, wantStep: step - 1, wantOK: true},
+		{name: "next step within skew", code: next, skew: 1, wantStep: step + 1, wantOK: true},
+		{name: "next step without skew", code: next, skew: 0},
+		{name: "beyond skew", code: later, skew: 1},
+		{name: "wrong code", code: "000000", skew: 1},
+		{name: "too short", code: "05047", skew: 1},
+		{name: "too long", code: "0504710", skew: 1},
+	}
+	for _, tt := range tests {
+		gotStep, ok := Validate(rfcSecret, tt.code, now, tt.skew)
+		if ok != tt.wantOK || (ok && gotStep != tt.wantStep) {
+			t.Errorf("%s: Validate = (%d, %v), want (%d, %v)", tt.name, gotStep, ok, tt.wantStep, tt.wantOK)
+		}
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := encoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret %q is not base32: %v", secret, err)
+	}
+	if len(key) != 20 {
+		t.Errorf("secret has %d bytes, want 20", len(key))
+	}
+	if other, _ := GenerateSecret(); other == secret {
+		t.Error("GenerateSecret returned the same secret twice")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri, err := url.Parse(ProvisioningURI("Example App", "user@example.com", rfcSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Example App:user@example.com" {
+		t.Errorf("URI = %s, want otpauth://totp/Example App:user@example.com", uri)
+	}
+	query := uri.Query()
+	want := map[string]string{"secret": rfcSecret, "issuer": "Example App", "algorithm": "SHA1", "digits": "6", "period": "30"}
+	for key, value := range want {
+		if got := query.Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}